@@ -2,24 +2,50 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
 	"github.com/phoobynet/buffalo/data/configuration"
 	"github.com/phoobynet/buffalo/data/market/clock"
+	"github.com/phoobynet/buffalo/data/market/feed"
+	"github.com/phoobynet/buffalo/data/market/frame"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
 	"github.com/phoobynet/buffalo/data/market/stock"
+	"github.com/phoobynet/buffalo/data/market/stock/aggregate"
+	"github.com/phoobynet/buffalo/data/market/stock/alert"
 	"github.com/phoobynet/buffalo/data/market/stock/bar"
+	"github.com/phoobynet/buffalo/data/market/stock/conditions"
+	"github.com/phoobynet/buffalo/data/market/stock/indicator"
+	"github.com/phoobynet/buffalo/data/market/stock/performance"
+	"github.com/phoobynet/buffalo/data/market/stock/rvol"
+	"github.com/phoobynet/buffalo/data/market/stock/sales"
+	"github.com/phoobynet/buffalo/data/market/stock/scanner"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"github.com/phoobynet/buffalo/data/market/stock/trading"
+	"github.com/phoobynet/buffalo/data/market/stock/venues"
+	"github.com/phoobynet/buffalo/data/market/tape"
 	"github.com/phoobynet/buffalo/data/metadata/asset"
 	"github.com/phoobynet/buffalo/data/metadata/calendar"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"log"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	dashboardConsumer = "dashboard"
+	watchlistConsumer = "watchlist"
+	aggregateConsumer = "aggregate"
+	alertConsumer     = "alert"
+)
+
 // App struct
 type App struct {
 	mut                        sync.Mutex
@@ -29,7 +55,7 @@ type App struct {
 	db                         *gorm.DB
 	assetRepository            *asset.Repository
 	alpacaClient               *alpaca.Client
-	marketDataClient           *marketdata.Client
+	marketDataClient           source.HistoricalSource
 	stockStream                *stock.Stream
 	ready                      bool
 	trades                     chan stream.Trade
@@ -39,8 +65,35 @@ type App struct {
 	calendarRepository         *calendar.Repository
 	appConfigurationRepository *configuration.Repository
 	status                     chan clock.Status
+	streamStatus               chan stock.Status
+	tradingStatus              chan stream.TradingStatus
+	lulds                      chan stream.LULD
+	tradingTracker             *trading.Tracker
+	corrections                chan stream.TradeCorrection
+	cancelErrors               chan stream.TradeCancelError
+	ledger                     *sales.Ledger
+	updatedBars                chan stream.Bar
+	dailyBars                  chan stream.Bar
+	intradaySeries             *bar.SeriesStore
+	tickers                    *ticker.Store
+	aggregator                 *aggregate.Aggregator
+	indicators                 *indicator.Store
+	relativeVolumes            *rvol.Store
+	performances               *performance.Store
+	alerts                     *alert.Engine
+	alertDispatcher            *alert.Dispatcher
+	scanner                    *scanner.Scanner
+	scans                      chan scanner.Refreshed
+	feedSelector               *feed.Selector
+	feedStatus                 chan feed.Status
 	statusClock                *clock.Clock
 	barRepository              *bar.Repository
+	coalescer                  *frame.Coalescer
+	frameInterval              chan time.Duration
+	tapeDB                     *gorm.DB
+	recorder                   *tape.Recorder
+	cancelRecorder             context.CancelFunc
+	replayer                   *tape.Replayer
 }
 
 // NewApp creates a new App application struct
@@ -56,52 +109,143 @@ func NewApp() *App {
 	bars := make(chan stream.Bar, 100)
 
 	status := make(chan clock.Status, 1)
-	updateTicker := time.NewTicker(100 * time.Millisecond)
+	frameInterval := make(chan time.Duration, 1)
 	snapshotTicker := time.NewTicker(1 * time.Second)
 
 	app := &App{
-		db:     db,
-		trades: trades,
-		quotes: quotes,
-		bars:   bars,
-		status: status,
+		db:             db,
+		trades:         trades,
+		quotes:         quotes,
+		bars:           bars,
+		status:         status,
+		streamStatus:   make(chan stock.Status, 10),
+		tradingStatus:  make(chan stream.TradingStatus, 100),
+		lulds:          make(chan stream.LULD, 100),
+		tradingTracker: trading.NewTracker(),
+		corrections:    make(chan stream.TradeCorrection, 100),
+		cancelErrors:   make(chan stream.TradeCancelError, 100),
+		ledger:         sales.NewLedger(sales.DefaultCapacity),
+		updatedBars:    make(chan stream.Bar, 100),
+		dailyBars:      make(chan stream.Bar, 100),
+		tickers:        ticker.NewStore(),
+		indicators:     indicator.NewStore(),
+		scans:          make(chan scanner.Refreshed, 1),
+		feedStatus:     make(chan feed.Status, 10),
+		frameInterval:  frameInterval,
+		coalescer:      frame.NewCoalescer(),
 	}
 
 	go func(app *App) {
-		var lastTrade stream.Trade
-		var lastTradeEmitted stream.Trade
-		var lastQuote stream.Quote
-		var lastQuoteEmitted stream.Quote
-		var lastBar stream.Bar
-		var lastBarEmitted stream.Bar
+		frameTicker := time.NewTicker(frame.DefaultInterval)
+		prints := make(sales.Prints)
+		tickers := make(ticker.Tickers)
 
 		for {
 			select {
-			case lastTrade = <-app.trades:
-			case lastQuote = <-app.quotes:
-			case lastBar = <-app.bars:
+			case trade := <-app.trades:
+				sale, _ := app.ledger.ApplyTrade(trade)
+				prints[trade.Symbol] = append(prints[trade.Symbol], sale)
+				tickers[trade.Symbol] = app.checkAlerts(app.tickers.ApplyTrade(trade.Symbol, sale))
+				app.coalescer.AddTrade(trade)
+
+				if app.aggregator != nil {
+					app.aggregator.ApplyTrade(trade)
+				}
+			case correction := <-app.corrections:
+				amendment := app.ledger.ApplyCorrection(correction)
+				tickers[amendment.Symbol] = app.checkAlerts(app.tickers.ApplyAmendment(amendment))
+				app.Emit(amendment)
+			case cancelError := <-app.cancelErrors:
+				amendment := app.ledger.ApplyCancelError(cancelError)
+				tickers[amendment.Symbol] = app.checkAlerts(app.tickers.ApplyAmendment(amendment))
+				app.Emit(amendment)
+			case quote := <-app.quotes:
+				app.coalescer.AddQuote(quote)
+				tickers[quote.Symbol] = app.checkAlerts(app.tickers.ApplyQuote(quote))
+			case streamBar := <-app.bars:
+				app.coalescer.AddBar(streamBar)
+				app.applyBar(streamBar)
+
+				if app.alerts != nil {
+					app.notify(app.alerts.ApplyBar(streamBar.Symbol, bar.ToBar(streamBar)))
+				}
+			case updatedBar := <-app.updatedBars:
+				app.applyBar(updatedBar)
+			case dailyBar := <-app.dailyBars:
+				app.Emit(app.intradaySeries.ApplyDailyBar(dailyBar))
+				tickers[dailyBar.Symbol] = app.checkAlerts(app.tickers.ApplyDailyBar(dailyBar))
+			case tradingStatus := <-app.tradingStatus:
+				app.tradingTracker.ApplyStatus(tradingStatus)
+				app.Emit(tradingStatus)
+			case luld := <-app.lulds:
+				app.tradingTracker.ApplyLULD(luld)
+				app.Emit(luld)
 			case currentStatus := <-app.status:
 				app.Emit(currentStatus)
-			case <-updateTicker.C:
-				if lastTradeEmitted.ID != lastTrade.ID {
-					app.Emit(lastTrade)
-					lastTradeEmitted = lastTrade
+
+				if app.scanner != nil {
+					app.scanner.ApplyStatus(currentStatus)
+				}
+			case refreshed := <-app.scans:
+				app.Emit(refreshed)
+			case streamStatus := <-app.streamStatus:
+				app.Emit(streamStatus)
+
+				if streamStatus.State == stock.Connected {
+					go app.rewatchAlerts()
+				}
+
+				if streamStatus.State == stock.InsufficientSubscription &&
+					app.feedSelector.Fallback(errors.New(streamStatus.Error)) {
+					app.stockStream.Restart()
+				}
+			case feedStatus := <-app.feedStatus:
+				app.Emit(feedStatus)
+			case interval := <-app.frameInterval:
+				frameTicker.Reset(interval)
+			case <-frameTicker.C:
+				if nextFrame := app.coalescer.Flush(); nextFrame != nil {
+					app.Emit(nextFrame)
+				}
+
+				if len(prints) > 0 {
+					app.Emit(prints)
+					prints = make(sales.Prints)
 				}
 
-				if !lastQuoteEmitted.Timestamp.Equal(lastQuote.Timestamp) {
-					app.Emit(lastQuote)
-					lastQuoteEmitted = lastQuote
+				if len(tickers) > 0 {
+					app.Emit(tickers)
+
+					if app.performances != nil {
+						if performances := app.performances.ApplyTickers(tickers); len(performances) > 0 {
+							app.Emit(performances)
+						}
+					}
+
+					tickers = make(ticker.Tickers)
 				}
 
-				if !lastBarEmitted.Timestamp.Equal(lastBar.Timestamp) {
-					app.Emit(lastBar)
-					lastBarEmitted = lastBar
+				if app.aggregator != nil {
+					if updates := app.aggregator.Flush(); len(updates) > 0 {
+						app.Emit(updates)
+					}
 				}
 			case t := <-snapshotTicker.C:
+				if t.Second() == 0 && app.stockStream != nil && app.intradaySeries != nil {
+					app.intradaySeries.Retain(app.streamedSymbols()...)
+				}
+
 				if t.Second() == 0 && app.marketDataClient != nil && app.currentSymbol != "" {
 					log.Println("Getting snapshot")
-					snapshot := app.GetSnapshot(app.currentSymbol)
+					snapshot, err := app.snapshot(app.currentSymbol)
+
+					if err != nil {
+						log.Printf("failed to get snapshot for %s: %v", app.currentSymbol, err)
+						continue
+					}
+
 					app.Emit(snapshot)
+					tickers[app.currentSymbol] = app.trackAlerts(app.tickers.Seed(app.currentSymbol, snapshot))
 				}
 			}
 		}
@@ -110,6 +254,62 @@ func NewApp() *App {
 	return app
 }
 
+// streamedSymbols returns every symbol subscribed by at least one consumer
+func (a *App) streamedSymbols() []string {
+	subscriptions := a.stockStream.Subscriptions()
+	symbols := make([]string, 0, len(subscriptions))
+
+	for _, subscription := range subscriptions {
+		symbols = append(symbols, subscription.Symbol)
+	}
+
+	return symbols
+}
+
+// applyBar merges a minute bar into the symbol's intraday series and updates its indicators, relative volume and
+// performance anchors
+func (a *App) applyBar(streamBar stream.Bar) {
+	update := a.intradaySeries.ApplyBar(streamBar)
+	a.Emit(update)
+	a.performances.ApplyBar(update.Symbol, update.Bar)
+
+	if updates := a.indicators.ApplyBar(update.Symbol, update.Bar); len(updates) > 0 {
+		a.Emit(updates)
+	}
+
+	if relativeVolume, ok := a.relativeVolumes.ApplyBar(update.Symbol, update.Bar); ok {
+		a.Emit(relativeVolume)
+	}
+}
+
+// checkAlerts evaluates the symbol's alerts against its updated ticker
+func (a *App) checkAlerts(t ticker.Ticker) ticker.Ticker {
+	if a.alerts != nil {
+		a.notify(a.alerts.ApplyTicker(t))
+	}
+
+	return t
+}
+
+// notify emits the triggered alerts and delivers them to the alert sinks
+func (a *App) notify(triggers alert.Triggers) {
+	if len(triggers) == 0 {
+		return
+	}
+
+	a.Emit(triggers)
+	a.alertDispatcher.Dispatch(triggers)
+}
+
+// trackAlerts records a seeded ticker without evaluating alerts, so that seeding is not mistaken for a move
+func (a *App) trackAlerts(t ticker.Ticker) ticker.Ticker {
+	if a.alerts != nil {
+		a.alerts.Track(t)
+	}
+
+	return t
+}
+
 func (a *App) Emit(data any) {
 	if a.ctx == nil {
 		return
@@ -124,99 +324,288 @@ func (a *App) Emit(data any) {
 		eventName = "quote"
 	case stream.Bar:
 		eventName = "bar"
+	case *frame.Frame:
+		eventName = "frame"
+	case stream.TradingStatus:
+		eventName = "trading-status"
+	case stream.LULD:
+		eventName = "luld"
+	case sales.Amendment:
+		eventName = "trade-amendment"
+	case sales.Prints:
+		eventName = "time-and-sales"
+	case ticker.Tickers:
+		eventName = "ticker"
+	case aggregate.Updates:
+		eventName = "aggregate-bars"
+	case alert.Triggers:
+		eventName = "alert"
+	case scanner.Refreshed:
+		eventName = "scanner"
+	case bar.Update:
+		eventName = "intraday-update"
+	case indicator.Updates:
+		eventName = "indicators"
+	case rvol.RelativeVolume:
+		eventName = "relative-volume"
+	case performance.Performances:
+		eventName = "change-since"
 	case *marketdata.Snapshot:
 		eventName = "snapshot"
 	case *alpaca.Asset:
 		eventName = "asset"
-	case *clock.Status:
+	case clock.Status, *clock.Status:
 		eventName = "clock-status"
+	case stock.Status:
+		eventName = "stream-status"
+	case feed.Status:
+		eventName = "feed-status"
 	default:
 		panic(fmt.Sprintf("Unknown type: %T", data))
 	}
 
-	if eventName == "trade" {
-		streamTrade := data.(stream.Trade)
-
-		runtime.EventsEmit(a.ctx, eventName, map[string]interface{}{
-			"S": streamTrade.Symbol,
-			"p": streamTrade.Price,
-			"x": streamTrade.Exchange,
-			"s": streamTrade.Size,
-			"c": streamTrade.Conditions,
-			"t": streamTrade.Timestamp,
-			"i": streamTrade.ID,
-			"z": streamTrade.Tape,
-		})
-	} else if eventName == "quote" {
-		streamQuote := data.(stream.Quote)
-
-		runtime.EventsEmit(a.ctx, eventName, map[string]interface{}{
-			"S":  streamQuote.Symbol,
-			"bp": streamQuote.BidPrice,
-			"bs": streamQuote.BidSize,
-			"ap": streamQuote.AskPrice,
-			"as": streamQuote.AskSize,
-			"t":  streamQuote.Timestamp,
-			"c":  streamQuote.Conditions,
-			"z":  streamQuote.Tape,
-		})
-	} else if eventName == "bar" {
-		streamBar := data.(stream.Bar)
-
-		runtime.EventsEmit(a.ctx, eventName, map[string]interface{}{
-			"S":  streamBar.Symbol,
-			"o":  streamBar.Open,
-			"h":  streamBar.High,
-			"l":  streamBar.Low,
-			"c":  streamBar.Close,
-			"v":  streamBar.Volume,
-			"t":  streamBar.Timestamp,
-			"vw": streamBar.VWAP,
-			"n":  streamBar.TradeCount,
-		})
-	} else {
+	switch eventName {
+	case "trade-amendment", "ticker", "aggregate-bars":
+		runtime.EventsEmit(a.ctx, eventName, a.withDroppedTrades(data))
+	case "trade":
+		runtime.EventsEmit(a.ctx, eventName, tradeEvent(data.(stream.Trade)))
+	case "quote":
+		runtime.EventsEmit(a.ctx, eventName, quoteEvent(data.(stream.Quote)))
+	case "bar":
+		runtime.EventsEmit(a.ctx, eventName, barEvent(data.(stream.Bar)))
+	case "trading-status":
+		runtime.EventsEmit(a.ctx, eventName, tradingStatusEvent(data.(stream.TradingStatus)))
+	case "luld":
+		runtime.EventsEmit(a.ctx, eventName, luldEvent(data.(stream.LULD)))
+	case "frame":
+		runtime.EventsEmit(a.ctx, eventName, frameEvent(data.(*frame.Frame)))
+	default:
 		runtime.EventsEmit(a.ctx, eventName, data)
 	}
 }
 
+// droppedTrades returns the number of the symbol's trades discarded by the stream's backpressure policy
+func (a *App) droppedTrades(symbol string) uint64 {
+	if a.stockStream == nil {
+		return 0
+	}
+
+	return a.stockStream.DroppedTrades(strings.TrimSpace(strings.ToUpper(symbol)))
+}
+
+// withDroppedTrades sets the dropped trade count next to the volumes built from trades
+func (a *App) withDroppedTrades(data any) any {
+	switch values := data.(type) {
+	case sales.Amendment:
+		values.Summary.DroppedTrades = a.droppedTrades(values.Symbol)
+		return values
+	case ticker.Tickers:
+		for symbol, t := range values {
+			t.DroppedTrades = a.droppedTrades(symbol)
+			values[symbol] = t
+		}
+	case aggregate.Updates:
+		for i := range values {
+			values[i].DroppedTrades = a.droppedTrades(values[i].Symbol)
+		}
+	}
+
+	return data
+}
+
+func tradeEvent(streamTrade stream.Trade) map[string]interface{} {
+	return map[string]interface{}{
+		"S": streamTrade.Symbol,
+		"p": streamTrade.Price,
+		"x": streamTrade.Exchange,
+		"s": streamTrade.Size,
+		"c": streamTrade.Conditions,
+		"t": streamTrade.Timestamp,
+		"i": streamTrade.ID,
+		"z": streamTrade.Tape,
+	}
+}
+
+func quoteEvent(streamQuote stream.Quote) map[string]interface{} {
+	return map[string]interface{}{
+		"S":  streamQuote.Symbol,
+		"bp": streamQuote.BidPrice,
+		"bs": streamQuote.BidSize,
+		"ap": streamQuote.AskPrice,
+		"as": streamQuote.AskSize,
+		"t":  streamQuote.Timestamp,
+		"c":  streamQuote.Conditions,
+		"z":  streamQuote.Tape,
+	}
+}
+
+func barEvent(streamBar stream.Bar) map[string]interface{} {
+	return map[string]interface{}{
+		"S":  streamBar.Symbol,
+		"o":  streamBar.Open,
+		"h":  streamBar.High,
+		"l":  streamBar.Low,
+		"c":  streamBar.Close,
+		"v":  streamBar.Volume,
+		"t":  streamBar.Timestamp,
+		"vw": streamBar.VWAP,
+		"n":  streamBar.TradeCount,
+	}
+}
+
+func tradingStatusEvent(streamTradingStatus stream.TradingStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"S":  streamTradingStatus.Symbol,
+		"sc": streamTradingStatus.StatusCode,
+		"sm": streamTradingStatus.StatusMsg,
+		"rc": streamTradingStatus.ReasonCode,
+		"rm": streamTradingStatus.ReasonMsg,
+		"t":  streamTradingStatus.Timestamp,
+		"z":  streamTradingStatus.Tape,
+	}
+}
+
+func luldEvent(streamLULD stream.LULD) map[string]interface{} {
+	return map[string]interface{}{
+		"S": streamLULD.Symbol,
+		"u": streamLULD.LimitUpPrice,
+		"d": streamLULD.LimitDownPrice,
+		"i": streamLULD.Indicator,
+		"t": streamLULD.Timestamp,
+		"z": streamLULD.Tape,
+	}
+}
+
+// frameEvent groups the frame's changes by symbol, e.g. {"AAPL": {"trade": {...}, "quote": {...}}}
+func frameEvent(f *frame.Frame) map[string]map[string]interface{} {
+	symbols := make(map[string]map[string]interface{})
+
+	forSymbol := func(symbol string) map[string]interface{} {
+		changes, ok := symbols[symbol]
+
+		if !ok {
+			changes = make(map[string]interface{})
+			symbols[symbol] = changes
+		}
+
+		return changes
+	}
+
+	for symbol, streamTrade := range f.Trades {
+		forSymbol(symbol)["trade"] = tradeEvent(streamTrade)
+	}
+
+	for symbol, streamQuote := range f.Quotes {
+		forSymbol(symbol)["quote"] = quoteEvent(streamQuote)
+	}
+
+	for symbol, streamBar := range f.Bars {
+		forSymbol(symbol)["bar"] = barEvent(streamBar)
+	}
+
+	return symbols
+}
+
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
 	a.alpacaClient = alpaca.NewClient(alpaca.ClientOpts{})
-	a.marketDataClient = marketdata.NewClient(marketdata.ClientOpts{})
+
+	appConfigurationRepository, err := configuration.NewRepository(a.db)
+	fatal(err)
+	a.appConfigurationRepository = appConfigurationRepository
+
+	isEmpty, err := a.appConfigurationRepository.IsEmpty()
+	fatal(err)
+
+	preferredFeed, err := a.appConfigurationRepository.Feed()
+	fatal(err)
+	a.feedSelector = feed.NewSelector(preferredFeed, a.feedStatus)
+
+	var newStreamSource source.StreamFactory
+	var metadataSource source.MetadataSource
+
+	// BUFFALO_SOURCE=fake runs against a deterministic in-process market instead of Alpaca
+	if os.Getenv("BUFFALO_SOURCE") == "fake" {
+		fakeMarket := fake.NewMarket(fake.Options{Generate: true})
+		a.marketDataClient = fakeMarket
+		metadataSource = fakeMarket
+		newStreamSource = fakeMarket.Factory()
+	} else {
+		a.marketDataClient = marketdata.NewClient(marketdata.ClientOpts{})
+		metadataSource = a.alpacaClient
+
+		newStreamSource = func(onConnect, onDisconnect func()) source.StreamSource {
+			return stream.NewStocksClient(
+				a.feedSelector.Current(),
+				stream.WithConnectCallback(onConnect),
+				stream.WithDisconnectCallback(onDisconnect),
+			)
+		}
+	}
 
 	streamCtx, cancel := context.WithCancel(a.ctx)
 	a.streamCtx = streamCtx
 	a.cancelStream = cancel
 
-	assetRepository, err := asset.NewRepository(a.db, a.alpacaClient)
+	assetRepository, err := asset.NewRepository(a.db, metadataSource)
 	fatal(err)
 	a.assetRepository = assetRepository
 
-	calendarRepository, err := calendar.NewRepository(a.db, a.alpacaClient)
+	calendarRepository, err := calendar.NewRepository(a.db, metadataSource)
 	fatal(err)
 	a.calendarRepository = calendarRepository
 
-	barRepository, err := bar.NewRepository(a.marketDataClient, a.calendarRepository)
+	barRepository, err := bar.NewRepository(a.db, a.marketDataClient, a.calendarRepository, a.feedSelector)
 	fatal(err)
 	a.barRepository = barRepository
+	a.intradaySeries = bar.NewSeriesStore(a.barRepository)
+	a.relativeVolumes = rvol.NewStore(a.barRepository)
+	a.performances = performance.NewStore(a.barRepository, a.calendarRepository)
+	a.aggregator = aggregate.NewAggregator(a.marketDataClient, a.feedSelector)
+	a.scanner = scanner.NewScanner(a.marketDataClient, a.feedSelector, a.assetRepository, a.scans)
 
-	stockStream, err := stock.NewStream(a.streamCtx, a.trades, a.quotes, a.bars)
+	stockStream, err := stock.NewStream(a.streamCtx, newStreamSource, stock.Channels{
+		Trades:        a.trades,
+		Quotes:        a.quotes,
+		Bars:          a.bars,
+		UpdatedBars:   a.updatedBars,
+		DailyBars:     a.dailyBars,
+		TradingStatus: a.tradingStatus,
+		LULDs:         a.lulds,
+		Corrections:   a.corrections,
+		CancelErrors:  a.cancelErrors,
+		Statuses:      a.streamStatus,
+	})
 	fatal(err)
 	a.stockStream = stockStream
 
-	statusClock, err := clock.NewClock(a.ctx, a.status, a.calendarRepository)
+	alertRepository, err := alert.NewRepository(a.db)
 	fatal(err)
-	a.statusClock = statusClock
+	a.alerts, err = alert.NewEngine(alertRepository)
+	fatal(err)
+	a.alertDispatcher = alert.NewDispatcher(a.ctx, alertRepository)
+	a.rewatchAlerts()
+	a.seed(a.alerts.Symbols()...)
 
-	appConfigurationRepository, err := configuration.NewRepository(a.db)
+	tapeDB, err := tape.Open()
 	fatal(err)
-	a.appConfigurationRepository = appConfigurationRepository
+	a.tapeDB = tapeDB
+	a.replayer = tape.NewReplayer(a.tapeDB, a.trades, a.quotes, a.bars)
 
-	isEmpty, err := a.appConfigurationRepository.IsEmpty()
+	if os.Getenv("BUFFALO_RECORD_TAPE") != "" {
+		a.StartRecording()
+	}
+
+	statusClock, err := clock.NewClock(a.ctx, a.status, a.calendarRepository)
 	fatal(err)
+	a.statusClock = statusClock
+
+	frameInterval, err := a.appConfigurationRepository.FrameInterval()
+	fatal(err)
+	a.frameInterval <- frameInterval
 
 	if isEmpty {
 		x, y := runtime.WindowGetPosition(ctx)
@@ -235,8 +624,384 @@ func (a *App) startup(ctx context.Context) {
 	a.ready = true
 }
 
+// GetIntradayBars returns the symbol's intraday minute bars, including bars streamed since it was subscribed
 func (a *App) GetIntradayBars(symbol string) ([]marketdata.Bar, error) {
-	return a.barRepository.Intraday(symbol)
+	return a.intradaySeries.Get(symbol)
+}
+
+// GetBars returns the symbol's bars between start and end for a timeframe such as "1Min", "15Min", "1Hour",
+// "1Day", "1Week" or "1Month". The adjustment is "raw", "split", "dividend" or "all", defaulting to split.
+func (a *App) GetBars(symbol string, timeFrame string, start time.Time, end time.Time, adjustment string) ([]marketdata.Bar, error) {
+	parsedTimeFrame, err := bar.ParseTimeFrame(timeFrame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsedAdjustment, err := bar.ParseAdjustment(adjustment)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.barRepository.Bars(strings.TrimSpace(strings.ToUpper(symbol)), parsedTimeFrame, start, end, parsedAdjustment)
+}
+
+// GetRangePresets returns the chart range presets, shortest first
+func (a *App) GetRangePresets() []bar.Preset {
+	return bar.Presets
+}
+
+// GetRangeBars returns the symbol's bars for a preset (1D, 5D, 1M, 3M, 6M, YTD, 1Y, 5Y or Max) along with the
+// timeframe chosen for it
+func (a *App) GetRangeBars(symbol string, preset string) (*bar.Range, error) {
+	parsed, err := bar.ParsePreset(preset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.barRepository.Preset(strings.TrimSpace(strings.ToUpper(symbol)), parsed)
+}
+
+// GetSessionBars returns the symbol's bars for the latest number of trading days, grouped by day with the
+// pre-market, regular and post-market segments marked. The timeframe is a minute or hour timeframe such as "5Min".
+func (a *App) GetSessionBars(symbol string, days int, timeFrame string) (*bar.Sessions, error) {
+	parsedTimeFrame, err := bar.ParseTimeFrame(timeFrame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.barRepository.Sessions(strings.TrimSpace(strings.ToUpper(symbol)), days, parsedTimeFrame)
+}
+
+// GetResampledBars returns the symbol's bars from start to end resampled to the timeframe, optionally limited to
+// regular hours and with intraday bars anchored to the open rather than the hour
+func (a *App) GetResampledBars(symbol string, timeFrame string, start, end time.Time, regularHoursOnly, anchorToOpen bool) ([]marketdata.Bar, error) {
+	parsedTimeFrame, err := bar.ParseTimeFrame(timeFrame)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.intradaySeries.Resample(strings.TrimSpace(strings.ToUpper(symbol)), start, end, bar.ResampleOptions{
+		TimeFrame:        parsedTimeFrame,
+		RegularHoursOnly: regularHoursOnly,
+		AnchorToOpen:     anchorToOpen,
+	})
+}
+
+// GetIndicators computes the indicators described by the specs, e.g. "ema:9" or "bb:20,2", over the symbol's
+// intraday bars, replacing any previously requested for the symbol. They are kept current by the "indicators" event.
+func (a *App) GetIndicators(symbol string, specs []string) ([]indicator.Series, error) {
+	symbol = strings.TrimSpace(strings.ToUpper(symbol))
+	parsedSpecs := make([]indicator.Spec, len(specs))
+
+	for i, spec := range specs {
+		parsed, err := indicator.ParseSpec(spec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parsedSpecs[i] = parsed
+	}
+
+	bars, err := a.intradaySeries.Get(symbol)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.indicators.Set(symbol, parsedSpecs, bars), nil
+}
+
+// GetBarIndicators computes the indicators described by the specs over the symbol's bars for the timeframe from
+// start to end, e.g. to overlay a chart of GetBars or GetRangeBars. Unlike GetIndicators, they are not kept current.
+func (a *App) GetBarIndicators(symbol string, timeFrame string, start time.Time, end time.Time, adjustment string, specs []string) ([]indicator.Series, error) {
+	parsedSpecs := make([]indicator.Spec, len(specs))
+
+	for i, spec := range specs {
+		parsed, err := indicator.ParseSpec(spec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parsedSpecs[i] = parsed
+	}
+
+	bars, err := a.GetBars(symbol, timeFrame, start, end, adjustment)
+
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]indicator.Series, len(parsedSpecs))
+
+	for i, spec := range parsedSpecs {
+		series[i] = indicator.Compute(bars, spec)
+	}
+
+	return series, nil
+}
+
+// RemoveIndicators stops updating the symbol's indicators
+func (a *App) RemoveIndicators(symbol string) {
+	a.indicators.Remove(symbol)
+}
+
+// GetVolumeProfile returns the symbol's average cumulative volume at each minute of the session over the number of
+// trading days before it
+func (a *App) GetVolumeProfile(symbol string, days int) (*bar.VolumeProfile, error) {
+	return a.barRepository.VolumeProfile(strings.TrimSpace(strings.ToUpper(symbol)), days)
+}
+
+// GetRelativeVolume returns the symbol's volume so far this session relative to its average by the same time of day
+// over the number of trading days before it, 20 if not given. It is kept current by the "relative-volume" event.
+func (a *App) GetRelativeVolume(symbol string, days int) (rvol.RelativeVolume, error) {
+	symbol = strings.TrimSpace(strings.ToUpper(symbol))
+	bars, err := a.intradaySeries.Get(symbol)
+
+	if err != nil {
+		return rvol.RelativeVolume{}, err
+	}
+
+	return a.relativeVolumes.Set(symbol, days, bars)
+}
+
+// RemoveRelativeVolume stops updating the symbol's relative volume
+func (a *App) RemoveRelativeVolume(symbol string) {
+	a.relativeVolumes.Remove(symbol)
+}
+
+// GetChangeSince returns the symbol's change since today's open, the previous close, the pre-market open, 1W, 1M,
+// 3M, YTD, 1Y and its 52 week high and low. It is kept current by the "change-since" event as the last price changes.
+func (a *App) GetChangeSince(symbol string) (performance.Performance, error) {
+	symbol = strings.TrimSpace(strings.ToUpper(symbol))
+	bars, err := a.intradaySeries.Get(symbol)
+
+	if err != nil {
+		return performance.Performance{}, err
+	}
+
+	t := a.tickers.Get(symbol)
+
+	if t.Last == 0 {
+		snapshot, err := a.snapshot(symbol)
+
+		if err != nil {
+			return performance.Performance{}, err
+		}
+
+		t = a.trackAlerts(a.tickers.Seed(symbol, snapshot))
+	}
+
+	return a.performances.Set(symbol, bars, t)
+}
+
+// RemoveChangeSince stops updating the symbol's change since each horizon
+func (a *App) RemoveChangeSince(symbol string) {
+	a.performances.Remove(symbol)
+}
+
+// InvalidateBarCache removes the symbol's cached historical bars so that they are requested again
+func (a *App) InvalidateBarCache(symbol string) error {
+	return a.barRepository.Invalidate(strings.TrimSpace(strings.ToUpper(symbol)))
+}
+
+// seed loads the intraday bars and ticker of each symbol in the background
+func (a *App) seed(symbols ...string) {
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(strings.ToUpper(symbol))
+
+		if symbol == "" {
+			continue
+		}
+
+		go func(symbol string) {
+			if err := a.intradaySeries.Seed(symbol); err != nil {
+				log.Printf("failed to seed intraday bars for %s: %v", symbol, err)
+			}
+
+			snapshot, err := a.snapshot(symbol)
+
+			if err != nil {
+				log.Printf("failed to seed ticker for %s: %v", symbol, err)
+				return
+			}
+
+			a.Emit(ticker.Tickers{symbol: a.trackAlerts(a.tickers.Seed(symbol, snapshot))})
+		}(symbol)
+	}
+}
+
+// AddAggregateBars starts building bars for the symbol from its trades and returns the bars back-filled from
+// historical trades. The spec is an interval of whole seconds, e.g. "5s", or "tick:100", "volume:50000" or
+// "dollar:1000000". Completed and active bars are then emitted as "aggregate-bars" events.
+func (a *App) AddAggregateBars(symbol string, spec string) ([]aggregate.Bar, error) {
+	if _, err := aggregate.ParseSpec(spec); err != nil {
+		return nil, err
+	}
+
+	err := a.stockStream.Subscribe(aggregateConsumer, symbol)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.aggregator.Add(symbol, spec)
+}
+
+// GetAggregateBars returns the symbol's bars built for the spec, ending with the active bar
+func (a *App) GetAggregateBars(symbol string, spec string) ([]aggregate.Bar, error) {
+	return a.aggregator.Get(symbol, spec)
+}
+
+// RemoveAggregateBars stops building bars for the symbol and spec
+func (a *App) RemoveAggregateBars(symbol string, spec string) error {
+	err := a.aggregator.Remove(symbol, spec)
+
+	if err != nil {
+		return err
+	}
+
+	if len(a.aggregator.Specs(symbol)) == 0 {
+		return a.stockStream.Unsubscribe(aggregateConsumer, symbol)
+	}
+
+	return nil
+}
+
+// CreateAlert stores a new alert and streams its symbol
+func (a *App) CreateAlert(newAlert alert.Alert) (*alert.Alert, error) {
+	created, err := a.alerts.Create(newAlert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.watchAlerts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.seed(created.Symbol)
+
+	return created, nil
+}
+
+// GetAlerts returns every alert, including paused alerts
+func (a *App) GetAlerts() ([]alert.Alert, error) {
+	return a.alerts.List()
+}
+
+// PauseAlert stops evaluating the alert until it is resumed
+func (a *App) PauseAlert(id uint) error {
+	err := a.alerts.SetPaused(id, true)
+
+	if err != nil {
+		return err
+	}
+
+	return a.watchAlerts()
+}
+
+// ResumeAlert evaluates the alert again, re-arming it if it only triggers once
+func (a *App) ResumeAlert(id uint) error {
+	err := a.alerts.SetPaused(id, false)
+
+	if err != nil {
+		return err
+	}
+
+	err = a.watchAlerts()
+
+	if err != nil {
+		return err
+	}
+
+	// the ticker may be stale if the symbol was not streamed while the alert was paused
+	a.seed(a.alerts.Symbols()...)
+
+	return nil
+}
+
+func (a *App) DeleteAlert(id uint) error {
+	err := a.alerts.Delete(id)
+
+	if err != nil {
+		return err
+	}
+
+	return a.watchAlerts()
+}
+
+// CreateAlertSink stores a new destination for triggered alerts: a webhook, an SMTP server or a command
+func (a *App) CreateAlertSink(sink alert.Sink) (*alert.Sink, error) {
+	return a.alertDispatcher.CreateSink(sink)
+}
+
+// GetAlertSinks returns every alert sink, including disabled sinks
+func (a *App) GetAlertSinks() ([]alert.Sink, error) {
+	return a.alertDispatcher.Sinks()
+}
+
+func (a *App) EnableAlertSink(id uint) error {
+	return a.alertDispatcher.SetDisabled(id, false)
+}
+
+func (a *App) DisableAlertSink(id uint) error {
+	return a.alertDispatcher.SetDisabled(id, true)
+}
+
+func (a *App) DeleteAlertSink(id uint) error {
+	return a.alertDispatcher.DeleteSink(id)
+}
+
+// TestAlertSink delivers a sample alert to the sink and returns the outcome
+func (a *App) TestAlertSink(id uint) (*alert.Delivery, error) {
+	return a.alertDispatcher.Test(id)
+}
+
+// GetAlertDeliveries returns the latest deliveries to alert sinks, newest first
+func (a *App) GetAlertDeliveries(limit int) ([]alert.Delivery, error) {
+	return a.alertDispatcher.Deliveries(limit)
+}
+
+// watchAlerts streams the symbols with active alerts
+func (a *App) watchAlerts() error {
+	return a.stockStream.SetSymbols(alertConsumer, a.alerts.Symbols()...)
+}
+
+// rewatchAlerts streams the symbols with active alerts, logging a failure rather than returning it. It is retried
+// whenever the stream connects.
+func (a *App) rewatchAlerts() {
+	if a.alerts == nil {
+		return
+	}
+
+	if err := a.watchAlerts(); err != nil {
+		log.Printf("failed to stream alert symbols, retrying when the stream reconnects: %v", err)
+	}
+}
+
+// Scan ranks the scanned symbols as "gainers", "losers", "most_active" or "gappers", filtered by price, volume,
+// exchange and asset attributes. The first scan starts the scanner, whose refreshes are announced by the "scanner"
+// event.
+func (a *App) Scan(ranking string, filter scanner.Filter) (*scanner.Result, error) {
+	return a.scanner.Scan(scanner.Ranking(strings.ToLower(strings.TrimSpace(ranking))), filter)
+}
+
+// SetScannerUniverse restricts the scanner to the symbols, or every active US equity if there are none
+func (a *App) SetScannerUniverse(symbols []string) {
+	a.scanner.SetUniverse(symbols)
+}
+
+func (a *App) GetScannerUniverse() []string {
+	return a.scanner.Universe()
 }
 
 func (a *App) GetCurrentCalendar() (*calendar.Calendar, error) {
@@ -286,22 +1051,268 @@ func (a *App) GetSnapshot(symbol string) *marketdata.Snapshot {
 		return nil
 	}
 
-	snapshot, err := a.marketDataClient.GetSnapshot(symbol, marketdata.GetSnapshotRequest{})
+	snapshot, err := a.snapshot(symbol)
 	fatal(err)
 
 	return snapshot
 }
 
+func (a *App) snapshot(symbol string) (*marketdata.Snapshot, error) {
+	var snapshot *marketdata.Snapshot
+
+	err := a.feedSelector.Do(func(f marketdata.Feed) error {
+		var err error
+		snapshot, err = a.marketDataClient.GetSnapshot(symbol, marketdata.GetSnapshotRequest{Feed: f})
+
+		return err
+	})
+
+	return snapshot, err
+}
+
+// GetTicker returns the symbol's latest price, quote and session statistics
+func (a *App) GetTicker(symbol string) ticker.Ticker {
+	t := a.tickers.Get(symbol)
+	t.DroppedTrades = a.droppedTrades(symbol)
+
+	return t
+}
+
+// GetFrameInterval returns the interval between frame events in milliseconds
+func (a *App) GetFrameInterval() (int64, error) {
+	frameInterval, err := a.appConfigurationRepository.FrameInterval()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return frameInterval.Milliseconds(), nil
+}
+
+// SetFrameInterval changes and persists the interval between frame events in milliseconds
+func (a *App) SetFrameInterval(milliseconds int64) error {
+	if milliseconds <= 0 {
+		return fmt.Errorf("frame interval must be positive, got %d", milliseconds)
+	}
+
+	frameInterval := time.Duration(milliseconds) * time.Millisecond
+
+	err := a.appConfigurationRepository.UpdateFrameInterval(frameInterval)
+
+	if err != nil {
+		return err
+	}
+
+	a.frameInterval <- frameInterval
+
+	return nil
+}
+
 func (a *App) IsReady() bool {
 	return a.ready
 }
 
+// Subscribe makes symbol the dashboard's current symbol, releasing the previous one
 func (a *App) Subscribe(symbol string) error {
-	return a.stockStream.SubscribeTo(symbol)
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	err := a.stockStream.SetSymbols(dashboardConsumer, symbol)
+
+	if err != nil {
+		return err
+	}
+
+	a.currentSymbol = strings.TrimSpace(strings.ToUpper(symbol))
+	a.seed(a.currentSymbol)
+
+	return nil
 }
 
 func (a *App) Unsubscribe(symbol string) error {
-	return a.stockStream.UnsubscribeFrom(symbol)
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	err := a.stockStream.Unsubscribe(dashboardConsumer, symbol)
+
+	if err != nil {
+		return err
+	}
+
+	if a.currentSymbol == strings.TrimSpace(strings.ToUpper(symbol)) {
+		a.currentSymbol = ""
+	}
+
+	return nil
+}
+
+// GetFeedStatus returns the preferred data feed and the feed currently in use
+func (a *App) GetFeedStatus() feed.Status {
+	return a.feedSelector.Status()
+}
+
+// SetFeed changes and persists the preferred data feed, "sip" or "iex", reconnecting the stream to use it
+func (a *App) SetFeed(preferred string) error {
+	f, err := feed.Parse(preferred)
+
+	if err != nil {
+		return err
+	}
+
+	err = a.appConfigurationRepository.UpdateFeed(f)
+
+	if err != nil {
+		return err
+	}
+
+	a.feedSelector.Set(f)
+	a.stockStream.Restart()
+
+	return nil
+}
+
+// GetTradeSummary returns the symbol's last sale and day totals with every correction and cancel applied
+func (a *App) GetTradeSummary(symbol string) sales.Summary {
+	summary := a.ledger.Summary(symbol)
+	summary.DroppedTrades = a.droppedTrades(symbol)
+
+	return summary
+}
+
+// GetTradeConditions returns the trade condition codes used on the tape ("A", "B" or "C"), with their
+// descriptions and whether they update the last sale, high/low and volume
+func (a *App) GetTradeConditions(tape string) []conditions.Condition {
+	return conditions.All(conditions.PlanForTape(tape))
+}
+
+// GetExchanges returns the venue names for the exchange codes carried by trades and quotes
+func (a *App) GetExchanges() []venues.Exchange {
+	return venues.Exchanges()
+}
+
+// GetTapes returns the consolidated tapes
+func (a *App) GetTapes() []venues.Tape {
+	return venues.Tapes()
+}
+
+// GetVenueBreakdown returns the symbol's intraday volume and trade count by venue, including the off-exchange share
+func (a *App) GetVenueBreakdown(symbol string) venues.Breakdown {
+	breakdown := a.ledger.Venues(symbol)
+	breakdown.DroppedTrades = a.droppedTrades(symbol)
+
+	return breakdown
+}
+
+// GetTimeAndSales returns up to limit of the symbol's recent trades, newest first. Pass the previous page's
+// before cursor to continue with older trades, or zero to start from the latest trade.
+func (a *App) GetTimeAndSales(symbol string, limit int, before uint64) sales.Page {
+	return a.ledger.TimeAndSales(symbol, limit, before)
+}
+
+// GetTradingState returns the halt status and limit up/limit down bands of the symbol
+func (a *App) GetTradingState(symbol string) trading.State {
+	return a.tradingTracker.Get(symbol)
+}
+
+// GetStreamStatus returns the current state of the stream connection
+func (a *App) GetStreamStatus() stock.Status {
+	return a.stockStream.Status()
+}
+
+// ReconnectStream retries the stream connection after it has failed
+func (a *App) ReconnectStream() {
+	a.stockStream.Reconnect()
+}
+
+// GetStreamDiagnostics returns delivery and drop counts for each of the stream's channels
+func (a *App) GetStreamDiagnostics() []stock.ChannelStats {
+	return a.stockStream.Diagnostics()
+}
+
+// SetBackpressurePolicy changes what happens when the named stream channel is full. The channel is one of "trades",
+// "quotes", "bars", "updated-bars", "daily-bars", "trading-status", "lulds", "corrections" or "cancel-errors" and the
+// policy one of "block", "drop-oldest", "drop-newest" or "conflate-per-symbol". Trades block by default; with any
+// other policy the trades discarded are reported as droppedTrades next to the volumes built from them.
+func (a *App) SetBackpressurePolicy(channel string, policy string) error {
+	p, err := stock.ParsePolicy(policy)
+
+	if err != nil {
+		return err
+	}
+
+	return a.stockStream.SetPolicy(channel, p)
+}
+
+// SetWatchlist replaces the streamed watchlist symbols, subscribing and unsubscribing only the difference
+func (a *App) SetWatchlist(symbols []string) error {
+	err := a.stockStream.SetSymbols(watchlistConsumer, symbols...)
+
+	if err != nil {
+		return err
+	}
+
+	a.seed(symbols...)
+
+	return nil
+}
+
+// GetSubscriptions returns every streamed symbol and the consumers holding it
+func (a *App) GetSubscriptions() []stock.Subscription {
+	return a.stockStream.Subscriptions()
+}
+
+// StartRecording records every streamed trade, quote and bar to the tape database
+func (a *App) StartRecording() {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if a.recorder != nil {
+		return
+	}
+
+	recorderCtx, cancel := context.WithCancel(a.ctx)
+	a.cancelRecorder = cancel
+	a.recorder = tape.NewRecorder(recorderCtx, a.tapeDB)
+	a.stockStream.SetRecorder(a.recorder)
+}
+
+func (a *App) StopRecording() {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if a.recorder == nil {
+		return
+	}
+
+	a.stockStream.SetRecorder(nil)
+	a.cancelRecorder()
+	a.recorder = nil
+	a.cancelRecorder = nil
+}
+
+func (a *App) IsRecording() bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	return a.recorder != nil
+}
+
+// StartReplay plays the recorded tape back through the same path as the live stream
+func (a *App) StartReplay(options tape.ReplayOptions) error {
+	return a.replayer.Play(a.ctx, options)
+}
+
+// StepReplay releases the next recorded message when replaying in step mode
+func (a *App) StepReplay() {
+	a.replayer.Step()
+}
+
+func (a *App) StopReplay() {
+	a.replayer.Stop()
+}
+
+func (a *App) IsReplaying() bool {
+	return a.replayer.IsPlaying()
 }
 
 func (a *App) shutdown(ctx context.Context) {
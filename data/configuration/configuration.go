@@ -6,4 +6,8 @@ type AppConfiguration struct {
 	Y      int
 	Width  int
 	Height int
+	// FrameInterval is the interval between frame events in milliseconds
+	FrameInterval int64
+	// Feed is the preferred market data feed, "sip" or "iex"
+	Feed string
 }
@@ -1,7 +1,10 @@
 package configuration
 
 import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/frame"
 	"gorm.io/gorm"
+	"time"
 )
 
 type Repository struct {
@@ -64,3 +67,51 @@ func (r *Repository) UpdateWindow(x, y, width, height int) error {
 
 	return result.Error
 }
+
+// FrameInterval returns the configured interval between frame events, or the default if none has been set
+func (r *Repository) FrameInterval() (time.Duration, error) {
+	config, err := r.Get()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if config.FrameInterval <= 0 {
+		return frame.DefaultInterval, nil
+	}
+
+	return time.Duration(config.FrameInterval) * time.Millisecond, nil
+}
+
+func (r *Repository) UpdateFrameInterval(frameInterval time.Duration) error {
+	result := r.db.
+		Model(&AppConfiguration{}).
+		Where("key = ?", key).
+		UpdateColumn("frame_interval", frameInterval.Milliseconds())
+
+	return result.Error
+}
+
+// Feed returns the preferred market data feed, defaulting to SIP
+func (r *Repository) Feed() (marketdata.Feed, error) {
+	config, err := r.Get()
+
+	if err != nil {
+		return "", err
+	}
+
+	if config.Feed == "" {
+		return marketdata.SIP, nil
+	}
+
+	return config.Feed, nil
+}
+
+func (r *Repository) UpdateFeed(feed marketdata.Feed) error {
+	result := r.db.
+		Model(&AppConfiguration{}).
+		Where("key = ?", key).
+		UpdateColumn("feed", feed)
+
+	return result.Error
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"github.com/phoobynet/buffalo/data/configuration"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -12,7 +13,7 @@ func OpenDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	err = d.AutoMigrate(&AppConfiguration{})
+	err = d.AutoMigrate(&configuration.AppConfiguration{})
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,142 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Status describes the preferred feed and the feed actually in use
+type Status struct {
+	Preferred  marketdata.Feed `json:"preferred"`
+	Current    marketdata.Feed `json:"current"`
+	FallenBack bool            `json:"fallenBack"`
+	Warning    string          `json:"warning,omitempty"`
+}
+
+// Selector chooses the data feed used by both the stream and REST requests, falling back to IEX
+// when the account's subscription does not permit SIP.
+type Selector struct {
+	mut     sync.RWMutex
+	status  Status
+	changes chan Status
+}
+
+func Parse(feed string) (marketdata.Feed, error) {
+	switch f := strings.ToLower(strings.TrimSpace(feed)); f {
+	case marketdata.SIP, marketdata.IEX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported feed: %q", feed)
+	}
+}
+
+// NewSelector selects the preferred feed, sending every subsequent change to changes
+func NewSelector(preferred marketdata.Feed, changes chan Status) *Selector {
+	return &Selector{
+		status: Status{
+			Preferred: preferred,
+			Current:   preferred,
+		},
+		changes: changes,
+	}
+}
+
+// Current returns the feed requests should use
+func (s *Selector) Current() marketdata.Feed {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.status.Current
+}
+
+func (s *Selector) Status() Status {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.status
+}
+
+// Set changes the preferred feed, clearing any previous fallback
+func (s *Selector) Set(preferred marketdata.Feed) {
+	s.update(Status{
+		Preferred: preferred,
+		Current:   preferred,
+	})
+}
+
+// Fallback switches to IEX if SIP is currently in use, returning true if the feed changed
+func (s *Selector) Fallback(reason error) bool {
+	s.mut.RLock()
+	current := s.status
+	s.mut.RUnlock()
+
+	if current.Current != marketdata.SIP {
+		return false
+	}
+
+	s.update(Status{
+		Preferred:  current.Preferred,
+		Current:    marketdata.IEX,
+		FallenBack: true,
+		Warning:    fmt.Sprintf("SIP data rejected (%v), falling back to IEX", reason),
+	})
+
+	return true
+}
+
+func (s *Selector) update(status Status) {
+	s.mut.Lock()
+	s.status = status
+	s.mut.Unlock()
+
+	if s.changes == nil {
+		return
+	}
+
+	select {
+	case s.changes <- status:
+	default:
+	}
+}
+
+// Do calls fn with the current feed, retrying once with IEX if SIP is rejected
+func (s *Selector) Do(fn func(feed marketdata.Feed) error) error {
+	err := fn(s.Current())
+
+	if IsInsufficientSubscription(err) && s.Fallback(err) {
+		return fn(s.Current())
+	}
+
+	return err
+}
+
+// sipRejections are the messages of the 403 errors returned by the market data API when the account's subscription
+// does not permit the SIP data requested
+var sipRejections = map[string]bool{
+	"subscription does not permit querying recent SIP data": true,
+}
+
+// IsInsufficientSubscription returns true if err means the account may not use the requested feed
+func IsInsufficientSubscription(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, stream.ErrInsufficientSubscription) {
+		return true
+	}
+
+	var apiErr *alpaca.APIError
+
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusForbidden && sipRejections[strings.TrimSpace(apiErr.Message)]
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"net/http"
+	"testing"
+)
+
+func TestIsInsufficientSubscription(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"stream", stream.ErrInsufficientSubscription, true},
+		{"rest", &alpaca.APIError{StatusCode: http.StatusForbidden, Message: "subscription does not permit querying recent SIP data"}, true},
+		{"wrapped", fmt.Errorf("bars: %w", &alpaca.APIError{StatusCode: http.StatusForbidden, Message: "subscription does not permit querying recent SIP data"}), true},
+		{"other forbidden", &alpaca.APIError{StatusCode: http.StatusForbidden, Message: "forbidden: SIP account is disabled"}, false},
+		{"other status", &alpaca.APIError{StatusCode: http.StatusTooManyRequests, Message: "subscription does not permit querying recent SIP data"}, false},
+		{"other", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+
+	for _, test := range tests {
+		if actual := IsInsufficientSubscription(test.err); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
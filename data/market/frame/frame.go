@@ -0,0 +1,105 @@
+package frame
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the interval between frames when none has been configured
+const DefaultInterval = 100 * time.Millisecond
+
+// Frame holds the latest trade, quote and bar for every symbol that changed since the previous frame
+type Frame struct {
+	Trades map[string]stream.Trade
+	Quotes map[string]stream.Quote
+	Bars   map[string]stream.Bar
+}
+
+// IsEmpty returns true if nothing changed during the frame
+func (f *Frame) IsEmpty() bool {
+	return len(f.Trades) == 0 && len(f.Quotes) == 0 && len(f.Bars) == 0
+}
+
+// Symbols returns the symbols that changed during the frame
+func (f *Frame) Symbols() []string {
+	seen := make(map[string]bool)
+	var symbols []string
+
+	add := func(symbol string) {
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	for symbol := range f.Trades {
+		add(symbol)
+	}
+
+	for symbol := range f.Quotes {
+		add(symbol)
+	}
+
+	for symbol := range f.Bars {
+		add(symbol)
+	}
+
+	return symbols
+}
+
+// Coalescer keeps only the latest state per symbol and event type until it is flushed
+type Coalescer struct {
+	mut     sync.Mutex
+	current *Frame
+}
+
+func NewCoalescer() *Coalescer {
+	return &Coalescer{
+		current: newFrame(),
+	}
+}
+
+func newFrame() *Frame {
+	return &Frame{
+		Trades: make(map[string]stream.Trade),
+		Quotes: make(map[string]stream.Quote),
+		Bars:   make(map[string]stream.Bar),
+	}
+}
+
+func (c *Coalescer) AddTrade(trade stream.Trade) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.current.Trades[trade.Symbol] = trade
+}
+
+func (c *Coalescer) AddQuote(quote stream.Quote) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.current.Quotes[quote.Symbol] = quote
+}
+
+func (c *Coalescer) AddBar(bar stream.Bar) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.current.Bars[bar.Symbol] = bar
+}
+
+// Flush returns the pending frame and starts a new one, or nil if nothing changed
+func (c *Coalescer) Flush() *Frame {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.current.IsEmpty() {
+		return nil
+	}
+
+	flushed := c.current
+	c.current = newFrame()
+
+	return flushed
+}
@@ -0,0 +1,247 @@
+package fake
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"math"
+	"strings"
+	"time"
+)
+
+const samplesPerBar = 12
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// bar builds a deterministic bar covering [start, start+duration)
+func (m *Market) bar(symbol string, start time.Time, duration time.Duration) marketdata.Bar {
+	step := duration / samplesPerBar
+
+	bar := marketdata.Bar{
+		Timestamp: start,
+		Open:      m.prices.at(symbol, start),
+		High:      math.Inf(-1),
+		Low:       math.Inf(1),
+	}
+
+	var notional float64
+
+	for i := 0; i < samplesPerBar; i++ {
+		t := start.Add(time.Duration(i) * step)
+		price := m.prices.at(symbol, t)
+		size := uint64(m.prices.size(symbol, t)) * uint64(math.Max(1, step.Minutes()))
+
+		bar.High = math.Max(bar.High, price)
+		bar.Low = math.Min(bar.Low, price)
+		bar.Close = price
+		bar.Volume += size
+		bar.TradeCount++
+		notional += price * float64(size)
+	}
+
+	bar.VWAP = math.Round(notional/float64(bar.Volume)*10_000) / 10_000
+
+	return bar
+}
+
+// isTrading returns true if a bar starting at t would contain trades, i.e. during the extended session on weekdays
+func isTrading(t time.Time, timeFrame marketdata.TimeFrame) bool {
+	local := t.In(newYork)
+
+	// weekly and monthly bars start on the first day of their period whether or not it is a trading day
+	if timeFrame.Unit == marketdata.Week || timeFrame.Unit == marketdata.Month {
+		return true
+	}
+
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+
+	if timeFrame.Unit != marketdata.Min {
+		return true
+	}
+
+	return local.Hour() >= 4 && local.Hour() < 20
+}
+
+func next(t time.Time, timeFrame marketdata.TimeFrame) time.Time {
+	n := timeFrame.N
+
+	if n <= 0 {
+		n = 1
+	}
+
+	switch timeFrame.Unit {
+	case marketdata.Hour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case marketdata.Day:
+		return t.AddDate(0, 0, n)
+	case marketdata.Week:
+		return t.AddDate(0, 0, 7*n)
+	case marketdata.Month:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+}
+
+func align(t time.Time, timeFrame marketdata.TimeFrame) time.Time {
+	switch timeFrame.Unit {
+	case marketdata.Min:
+		return t.Truncate(time.Minute)
+	case marketdata.Hour:
+		return t.Truncate(time.Hour)
+	case marketdata.Week:
+		local := t.In(newYork)
+		monday := local.AddDate(0, 0, -((int(local.Weekday()) + 6) % 7))
+		return time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, newYork)
+	case marketdata.Month:
+		local := t.In(newYork)
+		return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, newYork)
+	default:
+		local := t.In(newYork)
+		return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, newYork)
+	}
+}
+
+// GetBars returns deterministic bars for the requested range
+func (m *Market) GetBars(symbol string, req marketdata.GetBarsRequest) ([]marketdata.Bar, error) {
+	symbol = strings.ToUpper(symbol)
+
+	end := req.End
+
+	if end.IsZero() || end.After(m.options.Now()) {
+		end = m.options.Now()
+	}
+
+	var bars []marketdata.Bar
+
+	for t := align(req.Start, req.TimeFrame); !t.After(end); t = next(t, req.TimeFrame) {
+		if t.Before(req.Start) || !isTrading(t, req.TimeFrame) {
+			continue
+		}
+
+		start, duration := t, next(t, req.TimeFrame).Sub(t)
+
+		// daily and longer bars are sampled across the extended session of their first day
+		if req.TimeFrame.Unit != marketdata.Min && req.TimeFrame.Unit != marketdata.Hour {
+			start, duration = t.Add(4*time.Hour), 16*time.Hour
+		}
+
+		bar := m.bar(symbol, start, duration)
+		bar.Timestamp = t
+		bars = append(bars, bar)
+
+		if req.TotalLimit > 0 && len(bars) >= req.TotalLimit {
+			break
+		}
+	}
+
+	return bars, nil
+}
+
+// GetTrades returns a deterministic trade for every generation interval between start and end during the
+// extended session
+func (m *Market) GetTrades(symbol string, req marketdata.GetTradesRequest) ([]marketdata.Trade, error) {
+	symbol = strings.ToUpper(symbol)
+
+	end := req.End
+
+	if end.IsZero() || end.After(m.options.Now()) {
+		end = m.options.Now()
+	}
+
+	var trades []marketdata.Trade
+
+	for t := req.Start.Truncate(m.options.Interval); !t.After(end); t = t.Add(m.options.Interval) {
+		if t.Before(req.Start) || !isTrading(t, marketdata.OneMin) {
+			continue
+		}
+
+		trades = append(trades, marketdata.Trade{
+			ID:         t.UnixNano(),
+			Exchange:   "V",
+			Price:      m.prices.at(symbol, t),
+			Size:       m.prices.size(symbol, t),
+			Timestamp:  t,
+			Conditions: []string{"@"},
+			Tape:       "C",
+		})
+
+		if req.TotalLimit > 0 && len(trades) >= req.TotalLimit {
+			break
+		}
+	}
+
+	return trades, nil
+}
+
+// GetSnapshot returns a deterministic snapshot as of now
+func (m *Market) GetSnapshot(symbol string, _ marketdata.GetSnapshotRequest) (*marketdata.Snapshot, error) {
+	symbol = strings.ToUpper(symbol)
+	now := m.options.Now()
+
+	trade := m.trade(symbol, now)
+	quote := m.quote(symbol, now)
+
+	minuteBar := m.bar(symbol, now.Truncate(time.Minute).Add(-time.Minute), time.Minute)
+
+	dailyBars, err := m.GetBars(symbol, marketdata.GetBarsRequest{
+		TimeFrame: marketdata.OneDay,
+		Start:     now.AddDate(0, 0, -7),
+		End:       now,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &marketdata.Snapshot{
+		LatestTrade: &marketdata.Trade{
+			ID:         trade.ID,
+			Exchange:   trade.Exchange,
+			Price:      trade.Price,
+			Size:       trade.Size,
+			Timestamp:  trade.Timestamp,
+			Conditions: trade.Conditions,
+			Tape:       trade.Tape,
+		},
+		LatestQuote: &marketdata.Quote{
+			BidExchange: quote.BidExchange,
+			BidPrice:    quote.BidPrice,
+			BidSize:     quote.BidSize,
+			AskExchange: quote.AskExchange,
+			AskPrice:    quote.AskPrice,
+			AskSize:     quote.AskSize,
+			Timestamp:   quote.Timestamp,
+			Conditions:  quote.Conditions,
+			Tape:        quote.Tape,
+		},
+		MinuteBar: &minuteBar,
+	}
+
+	if n := len(dailyBars); n > 0 {
+		snapshot.DailyBar = &dailyBars[n-1]
+	}
+
+	if n := len(dailyBars); n > 1 {
+		snapshot.PrevDailyBar = &dailyBars[n-2]
+	}
+
+	return snapshot, nil
+}
+
+// GetSnapshots returns the snapshot of each symbol
+func (m *Market) GetSnapshots(symbols []string, req marketdata.GetSnapshotRequest) (map[string]*marketdata.Snapshot, error) {
+	snapshots := make(map[string]*marketdata.Snapshot, len(symbols))
+
+	for _, symbol := range symbols {
+		snapshot, err := m.GetSnapshot(symbol, req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots[strings.ToUpper(symbol)] = snapshot
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,528 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlreadyConnected = errors.New("fake market already connected")
+	ErrNotConnected     = errors.New("fake market not connected")
+)
+
+// Options configures a fake Market
+type Options struct {
+	// Seed selects the deterministic price path
+	Seed int64
+	// Interval is how often trades and quotes are generated for subscribed symbols
+	Interval time.Duration
+	// Generate enables generated trades, quotes and bars. When disabled only pushed messages are streamed.
+	Generate bool
+	// Now returns the current time, defaulting to time.Now
+	Now func() time.Time
+}
+
+// Market is an in-process, deterministic and scriptable market data source implementing
+// source.StreamSource, source.HistoricalSource and source.MetadataSource.
+type Market struct {
+	mut          sync.Mutex
+	options      Options
+	prices       prices
+	connected    bool
+	cancel       context.CancelFunc
+	terminated   chan error
+	trades       map[string]bool
+	quotes       map[string]bool
+	bars         map[string]bool
+	updatedBars  map[string]bool
+	dailyBars    map[string]bool
+	statuses     map[string]bool
+	lulds        map[string]bool
+	onTrade      func(stream.Trade)
+	onQuote      func(stream.Quote)
+	onBar        func(stream.Bar)
+	onUpdatedBar func(stream.Bar)
+	onDailyBar   func(stream.Bar)
+	onStatus     func(stream.TradingStatus)
+	onLULD       func(stream.LULD)
+	onCorrection func(stream.TradeCorrection)
+	onCancel     func(stream.TradeCancelError)
+	onConnect    func()
+	onDisconnect func()
+	nextID       int64
+}
+
+var (
+	_ source.StreamSource     = (*Market)(nil)
+	_ source.HistoricalSource = (*Market)(nil)
+	_ source.MetadataSource   = (*Market)(nil)
+)
+
+func NewMarket(options Options) *Market {
+	if options.Interval <= 0 {
+		options.Interval = 250 * time.Millisecond
+	}
+
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	return &Market{
+		options:     options,
+		prices:      prices{seed: options.Seed},
+		terminated:  make(chan error, 1),
+		trades:      make(map[string]bool),
+		quotes:      make(map[string]bool),
+		bars:        make(map[string]bool),
+		updatedBars: make(map[string]bool),
+		dailyBars:   make(map[string]bool),
+		statuses:    make(map[string]bool),
+		lulds:       make(map[string]bool),
+	}
+}
+
+// Factory returns a source.StreamFactory that always hands out this market, so that it can be
+// reconnected after Terminate while keeping its script
+func (m *Market) Factory() source.StreamFactory {
+	return func(onConnect, onDisconnect func()) source.StreamSource {
+		m.mut.Lock()
+		defer m.mut.Unlock()
+
+		m.onConnect = onConnect
+		m.onDisconnect = onDisconnect
+		m.terminated = make(chan error, 1)
+
+		return m
+	}
+}
+
+func (m *Market) Connect(ctx context.Context) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.connected {
+		return ErrAlreadyConnected
+	}
+
+	m.connected = true
+
+	if m.onConnect != nil {
+		go m.onConnect()
+	}
+
+	connCtx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+
+	if m.options.Generate {
+		go m.generate(connCtx)
+	}
+
+	go func() {
+		<-connCtx.Done()
+		m.Terminate(nil)
+	}()
+
+	return nil
+}
+
+// Terminate disconnects the market, reporting err on the Terminated channel
+func (m *Market) Terminate(err error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if !m.connected {
+		return
+	}
+
+	m.connected = false
+	m.cancel()
+
+	if m.onDisconnect != nil {
+		go m.onDisconnect()
+	}
+
+	select {
+	case m.terminated <- err:
+	default:
+	}
+}
+
+func (m *Market) Terminated() <-chan error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.terminated
+}
+
+func (m *Market) generate(ctx context.Context) {
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+
+	lastMinute := m.options.Now().Truncate(time.Minute)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := m.options.Now()
+
+			for _, symbol := range m.subscribed(m.trades) {
+				m.PushTrade(m.trade(symbol, now))
+			}
+
+			for _, symbol := range m.subscribed(m.quotes) {
+				m.PushQuote(m.quote(symbol, now))
+			}
+
+			if minute := now.Truncate(time.Minute); minute.After(lastMinute) {
+				for _, symbol := range m.subscribed(m.bars) {
+					m.PushBar(m.streamBar(symbol, lastMinute, time.Minute))
+				}
+
+				for _, symbol := range m.subscribed(m.dailyBars) {
+					local := now.In(newYork)
+					sessionOpen := time.Date(local.Year(), local.Month(), local.Day(), 4, 0, 0, 0, newYork)
+
+					if now.After(sessionOpen) {
+						dailyBar := m.streamBar(symbol, sessionOpen, now.Sub(sessionOpen))
+						dailyBar.Timestamp = sessionOpen.Add(-4 * time.Hour)
+						m.PushDailyBar(dailyBar)
+					}
+				}
+
+				lastMinute = minute
+			}
+		}
+	}
+}
+
+func (m *Market) subscribed(symbols map[string]bool) []string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	subscribed := make([]string, 0, len(symbols))
+
+	for symbol := range symbols {
+		subscribed = append(subscribed, symbol)
+	}
+
+	sort.Strings(subscribed)
+
+	return subscribed
+}
+
+func (m *Market) trade(symbol string, t time.Time) stream.Trade {
+	m.mut.Lock()
+	m.nextID++
+	id := m.nextID
+	m.mut.Unlock()
+
+	return stream.Trade{
+		ID:         id,
+		Symbol:     symbol,
+		Exchange:   "V",
+		Price:      m.prices.at(symbol, t),
+		Size:       m.prices.size(symbol, t),
+		Timestamp:  t,
+		Conditions: []string{"@"},
+		Tape:       "C",
+	}
+}
+
+func (m *Market) quote(symbol string, t time.Time) stream.Quote {
+	price := m.prices.at(symbol, t)
+
+	return stream.Quote{
+		Symbol:      symbol,
+		BidExchange: "V",
+		BidPrice:    price - 0.01,
+		BidSize:     m.prices.size(symbol, t.Add(time.Millisecond)),
+		AskExchange: "V",
+		AskPrice:    price + 0.01,
+		AskSize:     m.prices.size(symbol, t.Add(2*time.Millisecond)),
+		Timestamp:   t,
+		Conditions:  []string{"R"},
+		Tape:        "C",
+	}
+}
+
+func (m *Market) streamBar(symbol string, start time.Time, duration time.Duration) stream.Bar {
+	bar := m.bar(symbol, start, duration)
+
+	return stream.Bar{
+		Symbol:     symbol,
+		Open:       bar.Open,
+		High:       bar.High,
+		Low:        bar.Low,
+		Close:      bar.Close,
+		Volume:     bar.Volume,
+		Timestamp:  bar.Timestamp,
+		TradeCount: bar.TradeCount,
+		VWAP:       bar.VWAP,
+	}
+}
+
+// PushTrade delivers the trade to the trade handler if the symbol is subscribed
+func (m *Market) PushTrade(trade stream.Trade) {
+	m.mut.Lock()
+	handler := m.onTrade
+	subscribed := m.connected && m.trades[trade.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(trade)
+	}
+}
+
+// PushQuote delivers the quote to the quote handler if the symbol is subscribed
+func (m *Market) PushQuote(quote stream.Quote) {
+	m.mut.Lock()
+	handler := m.onQuote
+	subscribed := m.connected && m.quotes[quote.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(quote)
+	}
+}
+
+// PushBar delivers the bar to the bar handler if the symbol is subscribed
+func (m *Market) PushBar(bar stream.Bar) {
+	m.mut.Lock()
+	handler := m.onBar
+	subscribed := m.connected && m.bars[bar.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(bar)
+	}
+}
+
+// PushUpdatedBar delivers the bar to the updated bar handler if the symbol is subscribed
+func (m *Market) PushUpdatedBar(bar stream.Bar) {
+	m.mut.Lock()
+	handler := m.onUpdatedBar
+	subscribed := m.connected && m.updatedBars[bar.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(bar)
+	}
+}
+
+// PushDailyBar delivers the bar to the daily bar handler if the symbol is subscribed
+func (m *Market) PushDailyBar(bar stream.Bar) {
+	m.mut.Lock()
+	handler := m.onDailyBar
+	subscribed := m.connected && m.dailyBars[bar.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(bar)
+	}
+}
+
+// PushTradingStatus delivers the halt or resume to the status handler if the symbol is subscribed
+func (m *Market) PushTradingStatus(status stream.TradingStatus) {
+	m.mut.Lock()
+	handler := m.onStatus
+	subscribed := m.connected && m.statuses[status.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(status)
+	}
+}
+
+// PushLULD delivers the limit up/limit down bands to the LULD handler if the symbol is subscribed
+func (m *Market) PushLULD(luld stream.LULD) {
+	m.mut.Lock()
+	handler := m.onLULD
+	subscribed := m.connected && m.lulds[luld.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(luld)
+	}
+}
+
+// PushCorrection delivers the correction to the correction handler if the symbol's trades are subscribed
+func (m *Market) PushCorrection(correction stream.TradeCorrection) {
+	m.mut.Lock()
+	handler := m.onCorrection
+	subscribed := m.connected && m.trades[correction.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(correction)
+	}
+}
+
+// PushCancelError delivers the cancel or error to the cancel handler if the symbol's trades are subscribed
+func (m *Market) PushCancelError(cancelError stream.TradeCancelError) {
+	m.mut.Lock()
+	handler := m.onCancel
+	subscribed := m.connected && m.trades[cancelError.Symbol]
+	m.mut.Unlock()
+
+	if subscribed && handler != nil {
+		handler(cancelError)
+	}
+}
+
+func (m *Market) subscribe(subscriptions map[string]bool, symbols []string) error {
+	if !m.connected {
+		return ErrNotConnected
+	}
+
+	for _, symbol := range symbols {
+		subscriptions[strings.ToUpper(symbol)] = true
+	}
+
+	return nil
+}
+
+func (m *Market) unsubscribe(subscriptions map[string]bool, symbols []string) error {
+	if !m.connected {
+		return ErrNotConnected
+	}
+
+	for _, symbol := range symbols {
+		delete(subscriptions, strings.ToUpper(symbol))
+	}
+
+	return nil
+}
+
+func (m *Market) SubscribeToTrades(handler func(stream.Trade), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onTrade = handler
+
+	return m.subscribe(m.trades, symbols)
+}
+
+func (m *Market) SubscribeToQuotes(handler func(stream.Quote), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onQuote = handler
+
+	return m.subscribe(m.quotes, symbols)
+}
+
+func (m *Market) SubscribeToBars(handler func(stream.Bar), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onBar = handler
+
+	return m.subscribe(m.bars, symbols)
+}
+
+func (m *Market) SubscribeToUpdatedBars(handler func(stream.Bar), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onUpdatedBar = handler
+
+	return m.subscribe(m.updatedBars, symbols)
+}
+
+func (m *Market) SubscribeToDailyBars(handler func(stream.Bar), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onDailyBar = handler
+
+	return m.subscribe(m.dailyBars, symbols)
+}
+
+func (m *Market) UnsubscribeFromTrades(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.trades, symbols)
+}
+
+func (m *Market) UnsubscribeFromQuotes(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.quotes, symbols)
+}
+
+func (m *Market) UnsubscribeFromBars(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.bars, symbols)
+}
+
+func (m *Market) UnsubscribeFromUpdatedBars(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.updatedBars, symbols)
+}
+
+func (m *Market) UnsubscribeFromDailyBars(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.dailyBars, symbols)
+}
+
+func (m *Market) SubscribeToStatuses(handler func(stream.TradingStatus), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onStatus = handler
+
+	return m.subscribe(m.statuses, symbols)
+}
+
+func (m *Market) SubscribeToLULDs(handler func(stream.LULD), symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onLULD = handler
+
+	return m.subscribe(m.lulds, symbols)
+}
+
+func (m *Market) UnsubscribeFromStatuses(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.statuses, symbols)
+}
+
+func (m *Market) UnsubscribeFromLULDs(symbols ...string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	return m.unsubscribe(m.lulds, symbols)
+}
+
+func (m *Market) RegisterCorrections(handler func(stream.TradeCorrection)) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onCorrection = handler
+}
+
+func (m *Market) RegisterCancelErrors(handler func(stream.TradeCancelError)) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.onCancel = handler
+}
@@ -0,0 +1,98 @@
+package fake
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"strings"
+	"time"
+)
+
+// symbols are the assets listed by the fake market. Any other symbol still has prices and bars.
+var symbols = []struct {
+	symbol   string
+	name     string
+	exchange string
+}{
+	{"AAPL", "Apple Inc. Common Stock", "NASDAQ"},
+	{"AMD", "Advanced Micro Devices, Inc. Common Stock", "NASDAQ"},
+	{"AMZN", "Amazon.com, Inc. Common Stock", "NASDAQ"},
+	{"F", "Ford Motor Company Common Stock", "NYSE"},
+	{"GE", "General Electric Company Common Stock", "NYSE"},
+	{"GOOGL", "Alphabet Inc. Class A Common Stock", "NASDAQ"},
+	{"IWM", "iShares Russell 2000 ETF", "ARCA"},
+	{"JPM", "JPMorgan Chase & Co. Common Stock", "NYSE"},
+	{"META", "Meta Platforms, Inc. Class A Common Stock", "NASDAQ"},
+	{"MSFT", "Microsoft Corporation Common Stock", "NASDAQ"},
+	{"NVDA", "NVIDIA Corporation Common Stock", "NASDAQ"},
+	{"QQQ", "Invesco QQQ Trust, Series 1", "NASDAQ"},
+	{"SPY", "SPDR S&P 500 ETF Trust", "ARCA"},
+	{"TSLA", "Tesla, Inc. Common Stock", "NASDAQ"},
+	{"XOM", "Exxon Mobil Corporation Common Stock", "NYSE"},
+}
+
+// GetAssets returns the fake market's listed assets, all active, tradable US equities
+func (m *Market) GetAssets(req alpaca.GetAssetsRequest) ([]alpaca.Asset, error) {
+	assets := make([]alpaca.Asset, 0, len(symbols))
+
+	if req.Status != "" && req.Status != string(alpaca.AssetActive) {
+		return assets, nil
+	}
+
+	if req.AssetClass != "" && req.AssetClass != string(alpaca.USEquity) {
+		return assets, nil
+	}
+
+	for i, s := range symbols {
+		if req.Exchange != "" && !strings.EqualFold(req.Exchange, s.exchange) {
+			continue
+		}
+
+		assets = append(assets, alpaca.Asset{
+			ID:           s.symbol,
+			Class:        alpaca.USEquity,
+			Exchange:     s.exchange,
+			Symbol:       s.symbol,
+			Name:         s.name,
+			Status:       alpaca.AssetActive,
+			Tradable:     true,
+			Marginable:   true,
+			Shortable:    i%3 != 2,
+			EasyToBorrow: i%3 == 0,
+			Fractionable: true,
+		})
+	}
+
+	return assets, nil
+}
+
+// GetCalendar returns every weekday from start to end as a trading day from 09:30 to 16:00, matching the days the
+// fake market trades
+func (m *Market) GetCalendar(req alpaca.GetCalendarRequest) ([]alpaca.CalendarDay, error) {
+	start := req.Start
+	end := req.End
+
+	if start.IsZero() {
+		start = m.options.Now().AddDate(-1, 0, 0)
+	}
+
+	if end.IsZero() {
+		end = m.options.Now().AddDate(1, 0, 0)
+	}
+
+	local := start.In(newYork)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, newYork)
+	days := make([]alpaca.CalendarDay, 0)
+
+	for ; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+
+		days = append(days, alpaca.CalendarDay{
+			Date:  day.Format("2006-01-02"),
+			Open:  "09:30",
+			Close: "16:00",
+		})
+	}
+
+	return days, nil
+}
@@ -0,0 +1,57 @@
+package fake
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// prices generates a deterministic price path for every symbol. The price at any instant only depends on the
+// seed, the symbol and the instant itself, so historical bars and streamed trades always agree.
+type prices struct {
+	seed int64
+}
+
+func (p prices) hash(symbol string, values ...int64) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(symbol))
+
+	for _, v := range append([]int64{p.seed}, values...) {
+		var b [8]byte
+
+		for i := range b {
+			b[i] = byte(v >> (8 * i))
+		}
+
+		_, _ = h.Write(b[:])
+	}
+
+	return h.Sum64()
+}
+
+// unit returns a deterministic value in [0, 1) for the symbol and values
+func (p prices) unit(symbol string, values ...int64) float64 {
+	return float64(p.hash(symbol, values...)%1_000_000) / 1_000_000
+}
+
+func (p prices) base(symbol string) float64 {
+	return math.Round((20+p.unit(symbol)*480)*100) / 100
+}
+
+// at returns the price of the symbol at the given instant
+func (p prices) at(symbol string, t time.Time) float64 {
+	base := p.base(symbol)
+	phase := p.unit(symbol, 1) * 2 * math.Pi
+	seconds := float64(t.Unix())
+
+	trend := 0.08 * math.Sin(seconds/(86_400*30)+phase)
+	swing := 0.02 * math.Sin(seconds/(3_600*3)+phase*2)
+	noise := 0.002 * (p.unit(symbol, t.Unix()) - 0.5)
+
+	return math.Round(base*(1+trend+swing+noise)*100) / 100
+}
+
+// size returns a deterministic trade size for the symbol at the given instant
+func (p prices) size(symbol string, t time.Time) uint32 {
+	return uint32(1 + p.hash(symbol, t.UnixNano(), 2)%500)
+}
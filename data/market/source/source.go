@@ -0,0 +1,54 @@
+package source
+
+import (
+	"context"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+)
+
+// StreamSource provides real-time stock market data. *stream.StocksClient implements it.
+type StreamSource interface {
+	Connect(ctx context.Context) error
+	Terminated() <-chan error
+	SubscribeToTrades(handler func(stream.Trade), symbols ...string) error
+	SubscribeToQuotes(handler func(stream.Quote), symbols ...string) error
+	SubscribeToBars(handler func(stream.Bar), symbols ...string) error
+	SubscribeToUpdatedBars(handler func(stream.Bar), symbols ...string) error
+	SubscribeToDailyBars(handler func(stream.Bar), symbols ...string) error
+	SubscribeToStatuses(handler func(stream.TradingStatus), symbols ...string) error
+	SubscribeToLULDs(handler func(stream.LULD), symbols ...string) error
+	UnsubscribeFromTrades(symbols ...string) error
+	UnsubscribeFromQuotes(symbols ...string) error
+	UnsubscribeFromBars(symbols ...string) error
+	UnsubscribeFromUpdatedBars(symbols ...string) error
+	UnsubscribeFromDailyBars(symbols ...string) error
+	UnsubscribeFromStatuses(symbols ...string) error
+	UnsubscribeFromLULDs(symbols ...string) error
+	RegisterCorrections(handler func(stream.TradeCorrection))
+	RegisterCancelErrors(handler func(stream.TradeCancelError))
+}
+
+// HistoricalSource provides historical stock market data. *marketdata.Client implements it.
+type HistoricalSource interface {
+	GetBars(symbol string, req marketdata.GetBarsRequest) ([]marketdata.Bar, error)
+	GetSnapshot(symbol string, req marketdata.GetSnapshotRequest) (*marketdata.Snapshot, error)
+	GetSnapshots(symbols []string, req marketdata.GetSnapshotRequest) (map[string]*marketdata.Snapshot, error)
+	GetTrades(symbol string, req marketdata.GetTradesRequest) ([]marketdata.Trade, error)
+}
+
+// MetadataSource provides the tradable assets and the trading calendar. *alpaca.Client implements it.
+type MetadataSource interface {
+	GetAssets(req alpaca.GetAssetsRequest) ([]alpaca.Asset, error)
+	GetCalendar(req alpaca.GetCalendarRequest) ([]alpaca.CalendarDay, error)
+}
+
+var (
+	_ StreamSource     = (*stream.StocksClient)(nil)
+	_ HistoricalSource = (*marketdata.Client)(nil)
+	_ MetadataSource   = (*alpaca.Client)(nil)
+)
+
+// StreamFactory creates a new, unconnected StreamSource. onConnect and onDisconnect are called whenever the
+// source's underlying connection is established or lost.
+type StreamFactory func(onConnect, onDisconnect func()) StreamSource
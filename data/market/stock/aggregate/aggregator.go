@@ -0,0 +1,444 @@
+package aggregate
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/feed"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"github.com/phoobynet/buffalo/data/market/stock/conditions"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBackfill is how far back historical trades are aggregated when a series is added
+	DefaultBackfill = 15 * time.Minute
+	// MaxBackfillTrades limits the number of historical trades requested when a series is added
+	MaxBackfillTrades = 100_000
+	// MaxBars is the number of completed bars kept per series
+	MaxBars = 2_000
+)
+
+// Bar is a bar aggregated from trades. Time bars cover [t, e); other bars end at their last trade.
+type Bar struct {
+	Start      time.Time `json:"t"`
+	End        time.Time `json:"e"`
+	Open       float64   `json:"o"`
+	High       float64   `json:"h"`
+	Low        float64   `json:"l"`
+	Close      float64   `json:"c"`
+	Volume     uint64    `json:"v"`
+	TradeCount uint64    `json:"n"`
+	VWAP       float64   `json:"vw"`
+	Complete   bool      `json:"complete"`
+	notional   float64
+}
+
+// Update is a completed bar or a change to the active bar of a series
+type Update struct {
+	Symbol string `json:"symbol"`
+	Spec   string `json:"spec"`
+	Bar    Bar    `json:"bar"`
+	// DroppedTrades is the number of the symbol's trades discarded by the stream, missing from its bars
+	DroppedTrades uint64 `json:"droppedTrades"`
+}
+
+// Updates are the bars that completed or changed since they were last emitted
+type Updates []Update
+
+type trade struct {
+	price       float64
+	size        uint32
+	timestamp   time.Time
+	eligibility conditions.Eligibility
+}
+
+func newTrade(tape string, codes []string, price float64, size uint32, timestamp time.Time) trade {
+	return trade{
+		price:       price,
+		size:        size,
+		timestamp:   timestamp,
+		eligibility: conditions.Evaluate(tape, codes),
+	}
+}
+
+// add applies the trade according to its condition eligibility
+func (b *Bar) add(t trade) {
+	if b.Open == 0 && (t.eligibility.UpdatesHighLow || t.eligibility.UpdatesLast) {
+		b.Open = t.price
+	}
+
+	if t.eligibility.UpdatesHighLow {
+		if b.High == 0 || t.price > b.High {
+			b.High = t.price
+		}
+
+		if b.Low == 0 || t.price < b.Low {
+			b.Low = t.price
+		}
+	}
+
+	if t.eligibility.UpdatesLast {
+		b.Close = t.price
+	}
+
+	if t.eligibility.UpdatesVolume {
+		b.Volume += uint64(t.size)
+		b.notional += t.price * float64(t.size)
+		b.VWAP = b.notional / float64(b.Volume)
+	}
+
+	b.TradeCount++
+}
+
+type series struct {
+	symbol string
+	spec   Spec
+	bars   []Bar
+	active *Bar
+	// watermark is the start of the latest time bar, older trades are too late to be aggregated
+	watermark time.Time
+	lastClose float64
+	seeding   bool
+	pending   []trade
+	completed []Bar
+	changed   bool
+}
+
+func (s *series) open(start time.Time) {
+	s.active = &Bar{Start: start, End: start}
+
+	if s.spec.Kind == Time {
+		s.active.End = start.Add(s.spec.Interval)
+		s.watermark = start
+	}
+}
+
+// full returns true once the active bar has reached the spec's threshold
+func (s *series) full() bool {
+	switch s.spec.Kind {
+	case Tick:
+		return float64(s.active.TradeCount) >= s.spec.Threshold
+	case Volume:
+		return float64(s.active.Volume) >= s.spec.Threshold
+	case Dollar:
+		return s.active.notional >= s.spec.Threshold
+	default:
+		return false
+	}
+}
+
+func (s *series) apply(t trade) {
+	if !t.eligibility.UpdatesHighLow && !t.eligibility.UpdatesLast && !t.eligibility.UpdatesVolume {
+		return
+	}
+
+	if s.spec.Kind == Time {
+		start := t.timestamp.Truncate(s.spec.Interval)
+
+		if start.Before(s.watermark) {
+			return
+		}
+
+		if s.active != nil && start.After(s.active.Start) {
+			s.complete()
+		}
+
+		if s.active == nil {
+			s.open(start)
+		}
+	} else {
+		if s.active == nil {
+			s.open(t.timestamp)
+		}
+
+		s.active.End = t.timestamp
+	}
+
+	s.active.add(t)
+	s.changed = true
+
+	if s.full() {
+		s.complete()
+	}
+}
+
+// complete closes the active bar. A bar without any price eligible trades takes the previous bar's close.
+func (s *series) complete() {
+	bar := *s.active
+	bar.Complete = true
+
+	if bar.Open == 0 {
+		bar.Open = s.lastClose
+	}
+
+	if bar.High == 0 {
+		bar.High = bar.Open
+		bar.Low = bar.Open
+	}
+
+	if bar.Close == 0 {
+		bar.Close = bar.Open
+	}
+
+	s.bars = append(s.bars, bar)
+
+	if len(s.bars) > MaxBars {
+		s.bars = s.bars[len(s.bars)-MaxBars:]
+	}
+
+	s.completed = append(s.completed, bar)
+	s.lastClose = bar.Close
+	s.active = nil
+	s.changed = false
+}
+
+// elapse completes the active time bar once the stream has moved past its end
+func (s *series) elapse(now time.Time) {
+	if s.spec.Kind == Time && s.active != nil && !now.Before(s.active.End) {
+		s.complete()
+	}
+}
+
+func (s *series) list() []Bar {
+	bars := make([]Bar, len(s.bars), len(s.bars)+1)
+	copy(bars, s.bars)
+
+	if s.active != nil {
+		bars = append(bars, *s.active)
+	}
+
+	return bars
+}
+
+// Aggregator builds time, tick, volume and dollar bars from streamed trades. Each series is back-filled from
+// historical trades when it is added.
+type Aggregator struct {
+	mut              sync.Mutex
+	marketDataClient source.HistoricalSource
+	feedSelector     *feed.Selector
+	series           map[string]map[Spec]*series
+	// latest is the timestamp of the most recent trade, used as the stream's clock to complete time bars
+	latest time.Time
+}
+
+func NewAggregator(marketDataClient source.HistoricalSource, feedSelector *feed.Selector) *Aggregator {
+	return &Aggregator{
+		marketDataClient: marketDataClient,
+		feedSelector:     feedSelector,
+		series:           make(map[string]map[Spec]*series),
+	}
+}
+
+// getTrades requests trades from the selected feed, falling back to IEX if SIP is rejected
+func (a *Aggregator) getTrades(symbol string, request marketdata.GetTradesRequest) ([]marketdata.Trade, error) {
+	var trades []marketdata.Trade
+
+	err := a.feedSelector.Do(func(f marketdata.Feed) error {
+		var err error
+		request.Feed = f
+		trades, err = a.marketDataClient.GetTrades(symbol, request)
+
+		return err
+	})
+
+	return trades, err
+}
+
+// Add starts aggregating the symbol's trades according to the spec, back-filling it from the last
+// DefaultBackfill of historical trades, and returns its bars
+func (a *Aggregator) Add(symbol string, spec string) ([]Bar, error) {
+	parsed, err := ParseSpec(spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	a.mut.Lock()
+
+	if s, ok := a.series[symbol][parsed]; ok {
+		defer a.mut.Unlock()
+
+		return s.list(), nil
+	}
+
+	// trades streamed while the back-fill is requested are held until it has been applied
+	s := &series{
+		symbol:  symbol,
+		spec:    parsed,
+		seeding: true,
+	}
+
+	if _, ok := a.series[symbol]; !ok {
+		a.series[symbol] = make(map[Spec]*series)
+	}
+
+	a.series[symbol][parsed] = s
+	a.mut.Unlock()
+
+	end := time.Now()
+
+	trades, err := a.getTrades(symbol, marketdata.GetTradesRequest{
+		Start:      end.Add(-DefaultBackfill),
+		End:        end,
+		TotalLimit: MaxBackfillTrades,
+	})
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if err != nil {
+		if a.series[symbol][parsed] == s {
+			delete(a.series[symbol], parsed)
+		}
+
+		return nil, err
+	}
+
+	var cutoff time.Time
+
+	if len(s.pending) > 0 {
+		cutoff = s.pending[0].timestamp
+	}
+
+	for _, t := range trades {
+		if !cutoff.IsZero() && !t.Timestamp.Before(cutoff) {
+			break
+		}
+
+		s.apply(newTrade(t.Tape, t.Conditions, t.Price, t.Size, t.Timestamp))
+	}
+
+	for _, t := range s.pending {
+		s.apply(t)
+	}
+
+	s.elapse(a.latest)
+	s.pending = nil
+	s.seeding = false
+	// the back-filled bars are returned rather than emitted
+	s.completed = nil
+	s.changed = false
+
+	return s.list(), nil
+}
+
+// Remove stops aggregating the symbol's trades according to the spec
+func (a *Aggregator) Remove(symbol string, spec string) error {
+	parsed, err := ParseSpec(spec)
+
+	if err != nil {
+		return err
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	delete(a.series[symbol], parsed)
+
+	if len(a.series[symbol]) == 0 {
+		delete(a.series, symbol)
+	}
+
+	return nil
+}
+
+// Get returns the symbol's bars for the spec, oldest first, ending with the active bar
+func (a *Aggregator) Get(symbol string, spec string) ([]Bar, error) {
+	parsed, err := ParseSpec(spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if s, ok := a.series[strings.ToUpper(strings.TrimSpace(symbol))][parsed]; ok {
+		return s.list(), nil
+	}
+
+	return []Bar{}, nil
+}
+
+// Specs returns the specs of the symbol's series
+func (a *Aggregator) Specs(symbol string) []string {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	bySpec := a.series[strings.ToUpper(strings.TrimSpace(symbol))]
+	specs := make([]string, 0, len(bySpec))
+
+	for spec := range bySpec {
+		specs = append(specs, spec.String())
+	}
+
+	sort.Strings(specs)
+
+	return specs
+}
+
+// ApplyTrade adds the trade to every series of its symbol
+func (a *Aggregator) ApplyTrade(streamTrade stream.Trade) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if streamTrade.Timestamp.After(a.latest) {
+		a.latest = streamTrade.Timestamp
+	}
+
+	t := newTrade(streamTrade.Tape, streamTrade.Conditions, streamTrade.Price, streamTrade.Size, streamTrade.Timestamp)
+
+	for _, s := range a.series[streamTrade.Symbol] {
+		if s.seeding {
+			s.pending = append(s.pending, t)
+			continue
+		}
+
+		s.apply(t)
+	}
+}
+
+// Flush completes time bars the stream or the wall clock has moved past and returns the bars that completed or
+// changed since the last flush
+func (a *Aggregator) Flush() Updates {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	var updates Updates
+
+	// time bars still complete when no trades arrive
+	now := time.Now()
+
+	if a.latest.After(now) {
+		now = a.latest
+	}
+
+	for _, bySpec := range a.series {
+		for _, s := range bySpec {
+			if s.seeding {
+				continue
+			}
+
+			s.elapse(now)
+
+			for _, bar := range s.completed {
+				updates = append(updates, Update{Symbol: s.symbol, Spec: s.spec.String(), Bar: bar})
+			}
+
+			if s.changed && s.active != nil {
+				updates = append(updates, Update{Symbol: s.symbol, Spec: s.spec.String(), Bar: *s.active})
+			}
+
+			s.completed = nil
+			s.changed = false
+		}
+	}
+
+	return updates
+}
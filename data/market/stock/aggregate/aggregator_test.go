@@ -0,0 +1,28 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlushCompletesTimeBarsWithoutTrades(t *testing.T) {
+	spec, err := ParseSpec("5s")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &series{symbol: "AAPL", spec: spec}
+	s.open(time.Now().Add(-time.Minute).Truncate(spec.Interval))
+	s.apply(newTrade("C", []string{"@"}, 190.25, 100, s.active.Start))
+
+	a := NewAggregator(nil, nil)
+	a.series["AAPL"] = map[Spec]*series{spec: s}
+	a.latest = s.active.Start
+
+	updates := a.Flush()
+
+	if len(updates) != 1 || !updates[0].Bar.Complete || updates[0].Bar.Close != 190.25 {
+		t.Fatalf("expected the active bar to complete, got %+v", updates)
+	}
+}
@@ -0,0 +1,79 @@
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is what completes a bar
+type Kind string
+
+const (
+	// Time bars cover a fixed interval
+	Time Kind = "time"
+	// Tick bars hold a fixed number of trades
+	Tick Kind = "tick"
+	// Volume bars complete once they hold at least the threshold in shares
+	Volume Kind = "volume"
+	// Dollar bars complete once they hold at least the threshold in traded value
+	Dollar Kind = "dollar"
+)
+
+// MinInterval is the shortest interval of a time bar
+const MinInterval = time.Second
+
+var ErrInvalidSpec = errors.New("invalid bar spec")
+
+// Spec describes how trades are aggregated into bars
+type Spec struct {
+	Kind      Kind
+	Interval  time.Duration
+	Threshold float64
+}
+
+// ParseSpec parses a spec written as an interval, e.g. "5s", "15s" or "30s", or as kind:threshold, e.g.
+// "tick:100", "volume:50000" or "dollar:1000000"
+func ParseSpec(s string) (Spec, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	kind, threshold, found := strings.Cut(s, ":")
+
+	if !found {
+		interval, err := time.ParseDuration(s)
+
+		if err != nil || interval < MinInterval || interval%time.Second != 0 {
+			return Spec{}, fmt.Errorf("%w %q: intervals must be whole seconds", ErrInvalidSpec, s)
+		}
+
+		return Spec{Kind: Time, Interval: interval}, nil
+	}
+
+	spec := Spec{Kind: Kind(kind)}
+
+	switch spec.Kind {
+	case Tick, Volume, Dollar:
+	default:
+		return Spec{}, fmt.Errorf("%w %q: unknown kind %q", ErrInvalidSpec, s, kind)
+	}
+
+	value, err := strconv.ParseFloat(threshold, 64)
+
+	if err != nil || value <= 0 {
+		return Spec{}, fmt.Errorf("%w %q: the threshold must be positive", ErrInvalidSpec, s)
+	}
+
+	spec.Threshold = value
+
+	return spec, nil
+}
+
+func (s Spec) String() string {
+	if s.Kind == Time {
+		return s.Interval.String()
+	}
+
+	return fmt.Sprintf("%s:%s", s.Kind, strconv.FormatFloat(s.Threshold, 'f', -1, 64))
+}
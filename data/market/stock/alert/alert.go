@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Condition is what triggers an alert
+type Condition string
+
+const (
+	// PriceAbove triggers when the last price crosses above the value
+	PriceAbove Condition = "price_above"
+	// PriceBelow triggers when the last price crosses below the value
+	PriceBelow Condition = "price_below"
+	// ChangeAbove triggers when the percent change since the previous close reaches the value or more
+	ChangeAbove Condition = "change_above"
+	// ChangeBelow triggers when the percent change since the previous close reaches the value or less, e.g. -5
+	ChangeBelow Condition = "change_below"
+	// VolumeSpike triggers when a minute bar's volume is at least the value times the average of the previous
+	// Period minute bars
+	VolumeSpike Condition = "volume_spike"
+	// SpreadAbove triggers when the spread widens to the value, as a percentage of the mid price, or more
+	SpreadAbove Condition = "spread_above"
+	// NewHigh triggers when the session's high is exceeded
+	NewHigh Condition = "new_high"
+	// NewLow triggers when the session's low is undercut
+	NewLow Condition = "new_low"
+)
+
+// Mode is whether an alert keeps triggering
+type Mode string
+
+const (
+	// Once alerts pause when they trigger, resuming them re-arms them
+	Once Mode = "once"
+	// Recurring alerts trigger whenever their condition is met, at most once per cooldown
+	Recurring Mode = "recurring"
+)
+
+const (
+	// DefaultVolumePeriod is the number of minute bars averaged by a volume spike alert without a period
+	DefaultVolumePeriod = 20
+	// MaxVolumePeriod is the most minute bars a volume spike alert can average
+	MaxVolumePeriod = 390
+)
+
+var (
+	ErrInvalidAlert = errors.New("invalid alert")
+	ErrNotFound     = errors.New("alert not found")
+	ErrInvalidSink  = errors.New("invalid alert sink")
+	ErrSinkNotFound = errors.New("alert sink not found")
+)
+
+// Alert is a rule evaluated against a symbol's streamed trades, quotes and bars
+type Alert struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Symbol    string    `json:"symbol" gorm:"index"`
+	Condition Condition `json:"condition"`
+	Value     float64   `json:"value"`
+	// Period is the number of minute bars averaged by a volume spike
+	Period int  `json:"period"`
+	Mode   Mode `json:"mode"`
+	// CooldownSeconds is the least time between triggers of a recurring alert
+	CooldownSeconds int64      `json:"cooldownSeconds"`
+	Paused          bool       `json:"paused"`
+	Note            string     `json:"note"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	LastTriggeredAt *time.Time `json:"lastTriggeredAt"`
+	TriggerCount    int        `json:"triggerCount"`
+}
+
+// normalize applies the defaults and checks the alert can be evaluated
+func (a *Alert) normalize() error {
+	a.Symbol = strings.TrimSpace(strings.ToUpper(a.Symbol))
+
+	if a.Symbol == "" {
+		return fmt.Errorf("%w: a symbol is required", ErrInvalidAlert)
+	}
+
+	if a.Mode == "" {
+		a.Mode = Once
+	}
+
+	if a.Mode != Once && a.Mode != Recurring {
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidAlert, a.Mode)
+	}
+
+	if a.CooldownSeconds < 0 {
+		return fmt.Errorf("%w: the cooldown cannot be negative", ErrInvalidAlert)
+	}
+
+	switch a.Condition {
+	case PriceAbove, PriceBelow:
+		if a.Value <= 0 {
+			return fmt.Errorf("%w: the price must be positive", ErrInvalidAlert)
+		}
+	case ChangeAbove, ChangeBelow, NewHigh, NewLow:
+	case SpreadAbove:
+		if a.Value <= 0 {
+			return fmt.Errorf("%w: the spread must be positive", ErrInvalidAlert)
+		}
+	case VolumeSpike:
+		if a.Value <= 0 {
+			return fmt.Errorf("%w: the volume multiple must be positive", ErrInvalidAlert)
+		}
+
+		if a.Period == 0 {
+			a.Period = DefaultVolumePeriod
+		}
+
+		if a.Period < 1 || a.Period > MaxVolumePeriod {
+			return fmt.Errorf("%w: the period must be between 1 and %d", ErrInvalidAlert, MaxVolumePeriod)
+		}
+	default:
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidAlert, a.Condition)
+	}
+
+	return nil
+}
+
+func (a *Alert) cooldown() time.Duration {
+	return time.Duration(a.CooldownSeconds) * time.Second
+}
+
+// Trigger is an alert whose condition was met. Observed is the value that met it, e.g. the price, percent change,
+// volume multiple, spread percentage or new high.
+type Trigger struct {
+	Alert    Alert     `json:"alert"`
+	Observed float64   `json:"observed"`
+	Price    float64   `json:"price"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// Triggers are the alerts triggered by a trade, quote or bar
+type Triggers []Trigger
+
+func describe(a Alert, observed float64) string {
+	switch a.Condition {
+	case PriceAbove:
+		return fmt.Sprintf("%s crossed above %.2f at %.2f", a.Symbol, a.Value, observed)
+	case PriceBelow:
+		return fmt.Sprintf("%s crossed below %.2f at %.2f", a.Symbol, a.Value, observed)
+	case ChangeAbove, ChangeBelow:
+		return fmt.Sprintf("%s is %+.2f%% since the previous close", a.Symbol, observed)
+	case VolumeSpike:
+		return fmt.Sprintf("%s traded %.1fx its average minute volume", a.Symbol, observed)
+	case SpreadAbove:
+		return fmt.Sprintf("%s spread widened to %.2f%%", a.Symbol, observed)
+	case NewHigh:
+		return fmt.Sprintf("%s made a new high of day at %.2f", a.Symbol, observed)
+	default:
+		return fmt.Sprintf("%s made a new low of day at %.2f", a.Symbol, observed)
+	}
+}
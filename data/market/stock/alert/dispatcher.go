@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// retryDelay is the wait before the second delivery attempt, doubling before each attempt after it
+const retryDelay = time.Second
+
+// DeliveryStatus is the outcome of delivering a trigger to a sink
+type DeliveryStatus string
+
+const (
+	Delivered DeliveryStatus = "delivered"
+	Failed    DeliveryStatus = "failed"
+)
+
+// Delivery records the outcome of delivering a trigger to a sink. Error is the last attempt's error.
+type Delivery struct {
+	ID          uint           `json:"id" gorm:"primaryKey;autoIncrement"`
+	SinkID      uint           `json:"sinkId" gorm:"index"`
+	AlertID     uint           `json:"alertId" gorm:"index"`
+	Message     string         `json:"message"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	Error       string         `json:"error"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	CompletedAt time.Time      `json:"completedAt"`
+}
+
+// Dispatcher delivers triggered alerts to every enabled sink in the background, retrying failed attempts
+type Dispatcher struct {
+	ctx        context.Context
+	repository *Repository
+}
+
+func NewDispatcher(ctx context.Context, repository *Repository) *Dispatcher {
+	return &Dispatcher{
+		ctx:        ctx,
+		repository: repository,
+	}
+}
+
+// CreateSink stores a new sink
+func (d *Dispatcher) CreateSink(sink Sink) (*Sink, error) {
+	if err := sink.normalize(); err != nil {
+		return nil, err
+	}
+
+	sink.ID = 0
+
+	err := d.repository.CreateSink(&sink)
+
+	if err != nil {
+		return nil, err
+	}
+
+	created := sink.redacted()
+
+	return &created, nil
+}
+
+// Sinks returns every sink, including disabled sinks, without their passwords
+func (d *Dispatcher) Sinks() ([]Sink, error) {
+	sinks, err := d.repository.Sinks()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sinks {
+		sinks[i] = sinks[i].redacted()
+	}
+
+	return sinks, nil
+}
+
+// SetDisabled disables or enables the sink
+func (d *Dispatcher) SetDisabled(id uint, disabled bool) error {
+	return d.repository.SetSinkDisabled(id, disabled)
+}
+
+func (d *Dispatcher) DeleteSink(id uint) error {
+	return d.repository.DeleteSink(id)
+}
+
+// Deliveries returns the latest deliveries, newest first
+func (d *Dispatcher) Deliveries(limit int) ([]Delivery, error) {
+	return d.repository.Deliveries(limit)
+}
+
+// Dispatch delivers the triggers to every enabled sink without waiting for them to be delivered
+func (d *Dispatcher) Dispatch(triggers Triggers) {
+	sinks, err := d.repository.Sinks()
+
+	if err != nil {
+		log.Printf("failed to load alert sinks: %v", err)
+		return
+	}
+
+	for _, sink := range sinks {
+		if sink.Disabled {
+			continue
+		}
+
+		for _, trigger := range triggers {
+			go d.deliver(sink, trigger)
+		}
+	}
+}
+
+// Test delivers a sample trigger to the sink, waiting for the outcome
+func (d *Dispatcher) Test(id uint) (*Delivery, error) {
+	sink, err := d.repository.Sink(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sample := Alert{Symbol: "TEST", Condition: PriceAbove, Value: 100, Mode: Once}
+	now := time.Now()
+
+	return d.deliver(*sink, Trigger{
+		Alert:    sample,
+		Observed: 100.5,
+		Price:    100.5,
+		Message:  "Test alert: " + describe(sample, 100.5),
+		Time:     now,
+	}), nil
+}
+
+// deliver notifies the sink of the trigger, retrying until it succeeds or runs out of attempts, and records the
+// outcome
+func (d *Dispatcher) deliver(sink Sink, trigger Trigger) *Delivery {
+	delivery := &Delivery{
+		SinkID:    sink.ID,
+		AlertID:   trigger.Alert.ID,
+		Message:   trigger.Message,
+		Status:    Failed,
+		CreatedAt: time.Now(),
+	}
+
+	notifier, err := newNotifier(sink)
+
+	if err == nil {
+		err = d.attempt(sink, notifier, trigger, delivery)
+	}
+
+	if err == nil {
+		delivery.Status = Delivered
+	} else {
+		delivery.Error = err.Error()
+	}
+
+	delivery.CompletedAt = time.Now()
+
+	if err := d.repository.recordDelivery(delivery); err != nil {
+		log.Printf("failed to record delivery to alert sink %q: %v", sink.Name, err)
+	}
+
+	return delivery
+}
+
+// attempt notifies the sink until it succeeds, runs out of attempts or the dispatcher is stopped, returning the last
+// attempt's error
+func (d *Dispatcher) attempt(sink Sink, notifier Notifier, trigger Trigger, delivery *Delivery) error {
+	delay := retryDelay
+
+	for attempt := 1; ; attempt++ {
+		delivery.Attempts = attempt
+
+		ctx, cancel := context.WithTimeout(d.ctx, sink.timeout())
+		err := notifier.Notify(ctx, trigger)
+		cancel()
+
+		if err == nil || attempt >= sink.MaxAttempts {
+			return err
+		}
+
+		log.Printf("alert sink %q attempt %d failed: %v", sink.Name, attempt, err)
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-d.ctx.Done():
+			return err
+		}
+	}
+}
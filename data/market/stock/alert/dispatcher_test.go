@@ -0,0 +1,122 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repository, err := NewRepository(testutil.DB(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repository
+}
+
+func TestWebhookRetriesAfterFailedAttempt(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var trigger Trigger
+
+		if err := json.NewDecoder(r.Body).Decode(&trigger); err != nil || trigger.Alert.Symbol != "TEST" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(context.Background(), newTestRepository(t))
+
+	sink, err := dispatcher.CreateSink(Sink{Kind: Webhook, Target: server.URL})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery, err := dispatcher.Test(sink.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != Delivered || delivery.Attempts != 2 || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("expected delivery on the second attempt, got %+v after %d requests", delivery, requests)
+	}
+
+	deliveries, err := dispatcher.Deliveries(10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != 1 || deliveries[0].Status != Delivered {
+		t.Fatalf("unexpected delivery log %+v", deliveries)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(context.Background(), newTestRepository(t))
+
+	sink, err := dispatcher.CreateSink(Sink{Kind: Webhook, Target: server.URL, MaxAttempts: 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery, err := dispatcher.Test(sink.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != Failed || delivery.Attempts != 1 || delivery.Error == "" {
+		t.Fatalf("expected a failed delivery, got %+v", delivery)
+	}
+}
+
+func TestRecordDeliveryKeepsRetention(t *testing.T) {
+	repository := newTestRepository(t)
+
+	for id := uint(1); id <= 10; id++ {
+		if err := repository.db.Create(&Delivery{ID: id, Status: Delivered}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repository.recordDelivery(&Delivery{ID: DeliveryRetention + 5, Status: Delivered}); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := repository.Deliveries(0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 6 to 10 and the new delivery are within the retention of the newest
+	if len(deliveries) != 6 || deliveries[0].ID != DeliveryRetention+5 || deliveries[len(deliveries)-1].ID != 6 {
+		t.Fatalf("unexpected deliveries after pruning: %d, newest %d", len(deliveries), deliveries[0].ID)
+	}
+}
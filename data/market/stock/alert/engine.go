@@ -0,0 +1,279 @@
+package alert
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Engine evaluates the active alerts against each symbol's ticker and minute bars. Level conditions, such as a
+// percent change or spread, trigger when they become true rather than for as long as they remain true.
+type Engine struct {
+	mut        sync.Mutex
+	repository *Repository
+	// alerts are the active alerts by symbol
+	alerts map[string][]*Alert
+	// tickers are the latest ticker of each symbol, compared with the next to detect crosses
+	tickers map[string]ticker.Ticker
+	// volumes are the latest minute bar volumes of each symbol, oldest first
+	volumes map[string][]uint64
+}
+
+func NewEngine(repository *Repository) (*Engine, error) {
+	e := &Engine{
+		repository: repository,
+		tickers:    make(map[string]ticker.Ticker),
+		volumes:    make(map[string][]uint64),
+	}
+
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// load reads the active alerts from the repository
+func (e *Engine) load() error {
+	alerts, err := e.repository.All()
+
+	if err != nil {
+		return err
+	}
+
+	e.alerts = make(map[string][]*Alert)
+
+	for i := range alerts {
+		if !alerts[i].Paused {
+			e.alerts[alerts[i].Symbol] = append(e.alerts[alerts[i].Symbol], &alerts[i])
+		}
+	}
+
+	return nil
+}
+
+// Create stores a new alert and starts evaluating it
+func (e *Engine) Create(alert Alert) (*Alert, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if err := alert.normalize(); err != nil {
+		return nil, err
+	}
+
+	alert.ID = 0
+	alert.Paused = false
+	alert.LastTriggeredAt = nil
+	alert.TriggerCount = 0
+
+	err := e.repository.Create(&alert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &alert, e.load()
+}
+
+// List returns every alert, including paused alerts
+func (e *Engine) List() ([]Alert, error) {
+	return e.repository.All()
+}
+
+// SetPaused pauses or resumes the alert
+func (e *Engine) SetPaused(id uint, paused bool) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	err := e.repository.SetPaused(id, paused)
+
+	if err != nil {
+		return err
+	}
+
+	return e.load()
+}
+
+func (e *Engine) Delete(id uint) error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	err := e.repository.Delete(id)
+
+	if err != nil {
+		return err
+	}
+
+	return e.load()
+}
+
+// Symbols returns the symbols with active alerts
+func (e *Engine) Symbols() []string {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	symbols := make([]string, 0, len(e.alerts))
+
+	for symbol := range e.alerts {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+// Track records the symbol's ticker without evaluating its alerts, used when a ticker is seeded from a snapshot
+// rather than changed by a trade or quote
+func (e *Engine) Track(t ticker.Ticker) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	e.tickers[t.Symbol] = t
+}
+
+// ApplyTicker evaluates the symbol's price, change, spread and high/low of day alerts against its updated ticker
+func (e *Engine) ApplyTicker(t ticker.Ticker) Triggers {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	previous, seen := e.tickers[t.Symbol]
+	e.tickers[t.Symbol] = t
+
+	if len(e.alerts[t.Symbol]) == 0 {
+		return nil
+	}
+
+	return e.evaluate(t.Symbol, t.Last, func(a *Alert) (float64, bool) {
+		return met(a, previous, seen, t)
+	})
+}
+
+// ApplyBar evaluates the symbol's volume spike alerts against a completed minute bar
+func (e *Engine) ApplyBar(symbol string, bar marketdata.Bar) Triggers {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	history := e.volumes[symbol]
+	e.volumes[symbol] = append(history, bar.Volume)
+
+	if len(e.volumes[symbol]) > MaxVolumePeriod {
+		e.volumes[symbol] = e.volumes[symbol][1:]
+	}
+
+	if len(e.alerts[symbol]) == 0 {
+		return nil
+	}
+
+	return e.evaluate(symbol, bar.Close, func(a *Alert) (float64, bool) {
+		if a.Condition != VolumeSpike || len(history) < a.Period {
+			return 0, false
+		}
+
+		sum := uint64(0)
+
+		for _, volume := range history[len(history)-a.Period:] {
+			sum += volume
+		}
+
+		average := float64(sum) / float64(a.Period)
+
+		if average == 0 {
+			return 0, false
+		}
+
+		multiple := float64(bar.Volume) / average
+
+		return multiple, multiple >= a.Value
+	})
+}
+
+// evaluate triggers the symbol's alerts whose condition is met and whose cooldown has passed, pausing alerts that
+// only trigger once
+func (e *Engine) evaluate(symbol string, price float64, condition func(a *Alert) (float64, bool)) Triggers {
+	var triggers Triggers
+
+	now := time.Now()
+	active := e.alerts[symbol][:0]
+
+	for _, a := range e.alerts[symbol] {
+		observed, ok := condition(a)
+
+		if ok && (a.LastTriggeredAt == nil || now.Sub(*a.LastTriggeredAt) >= a.cooldown()) {
+			triggeredAt := now
+			a.LastTriggeredAt = &triggeredAt
+			a.TriggerCount++
+			a.Paused = a.Mode == Once
+
+			if err := e.repository.recordTrigger(a.ID, now, a.TriggerCount, a.Paused); err != nil {
+				log.Printf("failed to record trigger of alert %d: %v", a.ID, err)
+			}
+
+			triggers = append(triggers, Trigger{
+				Alert:    *a,
+				Observed: observed,
+				Price:    price,
+				Message:  describe(*a, observed),
+				Time:     now,
+			})
+		}
+
+		if !a.Paused {
+			active = append(active, a)
+		}
+	}
+
+	if len(active) == 0 {
+		delete(e.alerts, symbol)
+	} else {
+		e.alerts[symbol] = active
+	}
+
+	return triggers
+}
+
+func spreadPercent(t ticker.Ticker) (float64, bool) {
+	if t.Mid <= 0 || t.Spread < 0 {
+		return 0, false
+	}
+
+	return t.Spread / t.Mid * 100, true
+}
+
+// met returns the observed value and whether the alert's condition became true between the previous and current
+// ticker
+func met(a *Alert, previous ticker.Ticker, seen bool, current ticker.Ticker) (float64, bool) {
+	switch a.Condition {
+	case PriceAbove:
+		return current.Last, seen && previous.Last > 0 && previous.Last < a.Value && current.Last >= a.Value
+	case PriceBelow:
+		return current.Last, seen && previous.Last > a.Value && current.Last > 0 && current.Last <= a.Value
+	case ChangeAbove:
+		wasMet := seen && previous.PreviousClose > 0 && previous.ChangePercent >= a.Value
+
+		return current.ChangePercent, current.PreviousClose > 0 && current.ChangePercent >= a.Value && !wasMet
+	case ChangeBelow:
+		wasMet := seen && previous.PreviousClose > 0 && previous.ChangePercent <= a.Value
+
+		return current.ChangePercent, current.PreviousClose > 0 && current.ChangePercent <= a.Value && !wasMet
+	case SpreadAbove:
+		spread, ok := spreadPercent(current)
+		previousSpread, previousOk := spreadPercent(previous)
+		wasMet := seen && previousOk && previousSpread >= a.Value
+
+		return spread, ok && spread >= a.Value && !wasMet
+	case NewHigh:
+		sameSession := seen && previous.SessionDate == current.SessionDate
+
+		return current.High, sameSession && previous.High > 0 && current.High > previous.High
+	case NewLow:
+		sameSession := seen && previous.SessionDate == current.SessionDate
+
+		return current.Low, sameSession && previous.Low > 0 && current.Low > 0 && current.Low < previous.Low
+	default:
+		return 0, false
+	}
+}
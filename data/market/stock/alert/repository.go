@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// DeliveryRetention is the number of deliveries kept in the delivery log
+const DeliveryRetention = 10_000
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) (*Repository, error) {
+	err := db.AutoMigrate(&Alert{}, &Sink{}, &Delivery{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		db: db,
+	}, nil
+}
+
+// All returns every alert, oldest first
+func (r *Repository) All() ([]Alert, error) {
+	alerts := make([]Alert, 0)
+
+	result := r.db.Order("id asc").Find(&alerts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return alerts, nil
+}
+
+func (r *Repository) Create(alert *Alert) error {
+	return r.db.Create(alert).Error
+}
+
+// SetPaused pauses or resumes the alert
+func (r *Repository) SetPaused(id uint, paused bool) error {
+	result := r.db.Model(&Alert{}).Where("id = ?", id).UpdateColumn("paused", paused)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) Delete(id uint) error {
+	result := r.db.Delete(&Alert{}, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// recordTrigger stores when the alert last triggered, how often it has and whether it is now paused
+func (r *Repository) recordTrigger(id uint, at time.Time, count int, paused bool) error {
+	return r.db.Model(&Alert{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"last_triggered_at": at,
+		"trigger_count":     count,
+		"paused":            paused,
+	}).Error
+}
+
+// Sinks returns every sink, oldest first
+func (r *Repository) Sinks() ([]Sink, error) {
+	sinks := make([]Sink, 0)
+
+	result := r.db.Order("id asc").Find(&sinks)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return sinks, nil
+}
+
+func (r *Repository) Sink(id uint) (*Sink, error) {
+	var sinks []Sink
+
+	result := r.db.Where("id = ?", id).Limit(1).Find(&sinks)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if len(sinks) == 0 {
+		return nil, ErrSinkNotFound
+	}
+
+	return &sinks[0], nil
+}
+
+func (r *Repository) CreateSink(sink *Sink) error {
+	return r.db.Create(sink).Error
+}
+
+// SetSinkDisabled disables or enables the sink
+func (r *Repository) SetSinkDisabled(id uint, disabled bool) error {
+	result := r.db.Model(&Sink{}).Where("id = ?", id).UpdateColumn("disabled", disabled)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrSinkNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteSink(id uint) error {
+	result := r.db.Delete(&Sink{}, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrSinkNotFound
+	}
+
+	return nil
+}
+
+// Deliveries returns up to limit of the latest deliveries, newest first
+func (r *Repository) Deliveries(limit int) ([]Delivery, error) {
+	if limit <= 0 || limit > DeliveryRetention {
+		limit = DeliveryRetention
+	}
+
+	deliveries := make([]Delivery, 0)
+
+	result := r.db.Order("id desc").Limit(limit).Find(&deliveries)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return deliveries, nil
+}
+
+// recordDelivery stores the delivery, removing the oldest deliveries beyond the retention
+func (r *Repository) recordDelivery(delivery *Delivery) error {
+	err := r.db.Create(delivery).Error
+
+	if err != nil {
+		return err
+	}
+
+	return r.db.Where("id <= ?", int64(delivery.ID)-DeliveryRetention).Delete(&Delivery{}).Error
+}
@@ -0,0 +1,314 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SinkKind is how a sink delivers alerts
+type SinkKind string
+
+const (
+	// Webhook sinks POST the trigger as JSON to an HTTP endpoint
+	Webhook SinkKind = "webhook"
+	// Email sinks send the trigger as mail through an SMTP server
+	Email SinkKind = "email"
+	// Command sinks run a command with the trigger as JSON on stdin
+	Command SinkKind = "command"
+)
+
+const (
+	// DefaultSinkTimeout limits each delivery attempt of a sink without a timeout
+	DefaultSinkTimeout = 10 * time.Second
+	// DefaultMaxAttempts is the number of delivery attempts of a sink without a limit
+	DefaultMaxAttempts = 3
+	// MaxAttempts is the most delivery attempts of any sink
+	MaxAttempts = 10
+)
+
+// Sink is a configured destination for triggered alerts
+type Sink struct {
+	ID   uint     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name string   `json:"name"`
+	Kind SinkKind `json:"kind"`
+	// Target is the webhook's URL, the SMTP server's host:port or the command's path
+	Target string `json:"target"`
+	// Arguments are passed to a command
+	Arguments []string `json:"arguments" gorm:"serializer:json"`
+	// Username and Password authenticate with an SMTP server, if set. Password is write-only, it is never returned.
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// PasswordSet reports whether a password is stored in place of returning it
+	PasswordSet bool     `json:"passwordSet" gorm:"-"`
+	From        string   `json:"from"`
+	To          []string `json:"to" gorm:"serializer:json"`
+	// TimeoutSeconds limits each delivery attempt
+	TimeoutSeconds int       `json:"timeoutSeconds"`
+	MaxAttempts    int       `json:"maxAttempts"`
+	Disabled       bool      `json:"disabled"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// normalize applies the defaults and checks the sink can deliver
+func (s *Sink) normalize() error {
+	s.Target = strings.TrimSpace(s.Target)
+
+	if s.Name == "" {
+		s.Name = string(s.Kind)
+	}
+
+	if s.TimeoutSeconds < 0 {
+		return fmt.Errorf("%w: the timeout cannot be negative", ErrInvalidSink)
+	}
+
+	if s.MaxAttempts == 0 {
+		s.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if s.MaxAttempts < 1 || s.MaxAttempts > MaxAttempts {
+		return fmt.Errorf("%w: attempts must be between 1 and %d", ErrInvalidSink, MaxAttempts)
+	}
+
+	switch s.Kind {
+	case Webhook:
+		target, err := url.Parse(s.Target)
+
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("%w: the target must be an http or https URL", ErrInvalidSink)
+		}
+	case Email:
+		if _, _, err := net.SplitHostPort(s.Target); err != nil {
+			return fmt.Errorf("%w: the target must be the SMTP server's host:port", ErrInvalidSink)
+		}
+
+		if s.From == "" || len(s.To) == 0 {
+			return fmt.Errorf("%w: a sender and at least one recipient are required", ErrInvalidSink)
+		}
+	case Command:
+		if s.Target == "" {
+			return fmt.Errorf("%w: a command is required", ErrInvalidSink)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidSink, s.Kind)
+	}
+
+	return nil
+}
+
+// redacted returns the sink without its password, for returning to the frontend
+func (s Sink) redacted() Sink {
+	s.PasswordSet = s.Password != ""
+	s.Password = ""
+
+	return s
+}
+
+func (s *Sink) timeout() time.Duration {
+	if s.TimeoutSeconds == 0 {
+		return DefaultSinkTimeout
+	}
+
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
+
+// Notifier delivers a trigger, giving up when the context is done
+type Notifier interface {
+	Notify(ctx context.Context, trigger Trigger) error
+}
+
+func newNotifier(sink Sink) (Notifier, error) {
+	switch sink.Kind {
+	case Webhook:
+		return &webhook{sink: sink}, nil
+	case Email:
+		return &mailer{sink: sink}, nil
+	case Command:
+		return &command{sink: sink}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidSink, sink.Kind)
+	}
+}
+
+// truncate shortens output included in errors
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	return s[:length] + "…"
+}
+
+type webhook struct {
+	sink Sink
+}
+
+func (w *webhook) Notify(ctx context.Context, trigger Trigger) error {
+	payload, err := json.Marshal(trigger)
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.sink.Target, bytes.NewReader(payload))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s: %s", response.Status, truncate(strings.TrimSpace(string(body)), 200))
+	}
+
+	return nil
+}
+
+type mailer struct {
+	sink Sink
+}
+
+// message formats the trigger as a plain text mail with its JSON below the message
+func (m *mailer) message(trigger Trigger) ([]byte, error) {
+	payload, err := json.MarshalIndent(trigger, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\r\n", m.sink.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(m.sink.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", trigger.Message)
+	fmt.Fprintf(&message, "Date: %s\r\n", trigger.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&message, "%s\r\n\r\n%s\r\n", trigger.Message, strings.ReplaceAll(string(payload), "\n", "\r\n"))
+
+	return message.Bytes(), nil
+}
+
+func (m *mailer) Notify(ctx context.Context, trigger Trigger) error {
+	message, err := m.message(trigger)
+
+	if err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(m.sink.Target)
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", m.sink.Target)
+
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.sink.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.sink.Username, m.sink.Password, host))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(m.sink.From)
+
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range m.sink.To {
+		err = client.Rcpt(recipient)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(message)
+
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+type command struct {
+	sink Sink
+}
+
+func (c *command) Notify(ctx context.Context, trigger Trigger) error {
+	payload, err := json.Marshal(trigger)
+
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, c.sink.Target, c.sink.Arguments...)
+	cmd.Stdin = bytes.NewReader(payload)
+
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, truncate(strings.TrimSpace(string(output)), 200))
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleTrigger() Trigger {
+	a := Alert{ID: 7, Symbol: "AAPL", Condition: PriceAbove, Value: 190, Mode: Once}
+
+	return Trigger{
+		Alert:    a,
+		Observed: 190.25,
+		Price:    190.25,
+		Message:  describe(a, 190.25),
+		Time:     time.Date(2026, 10, 16, 14, 30, 0, 0, time.UTC),
+	}
+}
+
+// smtpStub accepts a single SMTP session on a local listener, replying 250 to every command, and sends the data it
+// receives on the returned channel
+func smtpStub(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost stub")
+
+		for {
+			line, err := reader.ReadString('\n')
+
+			if err != nil {
+				return
+			}
+
+			command := strings.ToUpper(strings.TrimSpace(line))
+
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case command == "DATA":
+				reply("354 end with <CRLF>.<CRLF>")
+
+				var data strings.Builder
+
+				for {
+					line, err := reader.ReadString('\n')
+
+					if err != nil {
+						return
+					}
+
+					if line == ".\r\n" {
+						break
+					}
+
+					data.WriteString(line)
+				}
+
+				received <- data.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestMailerSendsTriggerToStubServer(t *testing.T) {
+	address, received := smtpStub(t)
+
+	sink := Sink{Kind: Email, Target: address, From: "alerts@example.com", To: []string{"trader@example.com"}}
+
+	if err := sink.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, err := newNotifier(sink)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := notifier.Notify(ctx, sampleTrigger()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: AAPL crossed above 190.00 at 190.25") || !strings.Contains(data, "To: trader@example.com") {
+			t.Fatalf("unexpected message:\n%s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestCommandReceivesTriggerOnStdin(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "trigger.json")
+	sink := Sink{Kind: Command, Target: "sh", Arguments: []string{"-c", "cat > " + output}}
+
+	if err := sink.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, _ := newNotifier(sink)
+
+	if err := notifier.Notify(context.Background(), sampleTrigger()); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := os.ReadFile(output)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trigger Trigger
+
+	if err := json.Unmarshal(payload, &trigger); err != nil {
+		t.Fatal(err)
+	}
+
+	if trigger.Alert.ID != 7 || trigger.Price != 190.25 {
+		t.Fatalf("unexpected trigger %+v", trigger)
+	}
+}
+
+func TestCommandFailsOnNonZeroExit(t *testing.T) {
+	sink := Sink{Kind: Command, Target: "sh", Arguments: []string{"-c", "echo rejected; exit 3"}}
+
+	if err := sink.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, _ := newNotifier(sink)
+	err := notifier.Notify(context.Background(), sampleTrigger())
+
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("expected the exit status and output, got %v", err)
+	}
+}
+
+func TestSinkPasswordIsNotReturned(t *testing.T) {
+	sink := Sink{Kind: Email, Password: "secret"}.redacted()
+
+	if sink.Password != "" || !sink.PasswordSet {
+		t.Fatalf("unexpected redacted sink %+v", sink)
+	}
+
+	payload, _ := json.Marshal(sink)
+
+	if strings.Contains(string(payload), "secret") {
+		t.Fatalf("password returned in %s", payload)
+	}
+}
@@ -0,0 +1,208 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what happens to a message when its channel is full
+type Policy string
+
+const (
+	// Block waits until the channel has room, stalling the stream's read loop
+	Block Policy = "block"
+	// DropOldest discards the oldest queued message to make room
+	DropOldest Policy = "drop-oldest"
+	// DropNewest discards the message being sent
+	DropNewest Policy = "drop-newest"
+	// ConflatePerSymbol holds only the latest unsent message per symbol
+	ConflatePerSymbol Policy = "conflate-per-symbol"
+)
+
+func ParsePolicy(policy string) (Policy, error) {
+	switch p := Policy(policy); p {
+	case Block, DropOldest, DropNewest, ConflatePerSymbol:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown backpressure policy: %q", policy)
+	}
+}
+
+// ChannelStats reports how many messages were delivered or lost on a stream channel
+type ChannelStats struct {
+	Name      string `json:"name"`
+	Policy    Policy `json:"policy"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Conflated uint64 `json:"conflated"`
+	Length    int    `json:"length"`
+	Capacity  int    `json:"capacity"`
+}
+
+// outlet sends messages from the stream's handlers to a channel according to a backpressure policy
+type outlet[T any] struct {
+	// counters are accessed atomically and kept first for 64-bit alignment
+	delivered uint64
+	dropped   uint64
+	conflated uint64
+	name      string
+	out       chan T
+	symbolOf  func(T) string
+	mut       sync.Mutex
+	policy    Policy
+	pending   map[string]T
+	order     []string
+	signal    chan struct{}
+	// lost counts the dropped and conflated messages of each symbol
+	lost map[string]uint64
+}
+
+func newOutlet[T any](ctx context.Context, name string, out chan T, policy Policy, symbolOf func(T) string) *outlet[T] {
+	o := &outlet[T]{
+		name:     name,
+		out:      out,
+		symbolOf: symbolOf,
+		policy:   policy,
+		pending:  make(map[string]T),
+		signal:   make(chan struct{}, 1),
+		lost:     make(map[string]uint64),
+	}
+
+	go o.pump(ctx)
+
+	return o
+}
+
+func (o *outlet[T]) setPolicy(policy Policy) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	o.policy = policy
+}
+
+func (o *outlet[T]) currentPolicy() Policy {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	return o.policy
+}
+
+// lose counts a message that will not be delivered against its symbol
+func (o *outlet[T]) lose(message T) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	o.lost[o.symbolOf(message)]++
+}
+
+// lostFor returns the number of the symbol's messages that were dropped or conflated
+func (o *outlet[T]) lostFor(symbol string) uint64 {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+
+	return o.lost[symbol]
+}
+
+func (o *outlet[T]) send(message T) {
+	switch o.currentPolicy() {
+	case DropNewest:
+		select {
+		case o.out <- message:
+			atomic.AddUint64(&o.delivered, 1)
+		default:
+			atomic.AddUint64(&o.dropped, 1)
+			o.lose(message)
+		}
+	case DropOldest:
+		for {
+			select {
+			case o.out <- message:
+				atomic.AddUint64(&o.delivered, 1)
+				return
+			default:
+			}
+
+			select {
+			case oldest := <-o.out:
+				atomic.AddUint64(&o.dropped, 1)
+				o.lose(oldest)
+			default:
+			}
+		}
+	case ConflatePerSymbol:
+		o.conflate(message)
+	default:
+		o.out <- message
+		atomic.AddUint64(&o.delivered, 1)
+	}
+}
+
+func (o *outlet[T]) conflate(message T) {
+	symbol := o.symbolOf(message)
+
+	o.mut.Lock()
+
+	if _, ok := o.pending[symbol]; ok {
+		atomic.AddUint64(&o.conflated, 1)
+		o.lost[symbol]++
+	} else {
+		o.order = append(o.order, symbol)
+	}
+
+	o.pending[symbol] = message
+
+	o.mut.Unlock()
+
+	select {
+	case o.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pump delivers conflated messages in the order their symbols were first queued
+func (o *outlet[T]) pump(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.signal:
+		}
+
+		for {
+			o.mut.Lock()
+
+			if len(o.order) == 0 {
+				o.mut.Unlock()
+				break
+			}
+
+			symbol := o.order[0]
+			o.order = o.order[1:]
+			message := o.pending[symbol]
+			delete(o.pending, symbol)
+
+			o.mut.Unlock()
+
+			select {
+			case o.out <- message:
+				atomic.AddUint64(&o.delivered, 1)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (o *outlet[T]) stats() ChannelStats {
+	return ChannelStats{
+		Name:      o.name,
+		Policy:    o.currentPolicy(),
+		Delivered: atomic.LoadUint64(&o.delivered),
+		Dropped:   atomic.LoadUint64(&o.dropped),
+		Conflated: atomic.LoadUint64(&o.conflated),
+		Length:    len(o.out),
+		Capacity:  cap(o.out),
+	}
+}
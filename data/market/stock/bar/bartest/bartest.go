@@ -0,0 +1,23 @@
+// Package bartest provides a bar repository over the fake market for the tests of the packages built on bars
+package bartest
+
+import (
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/market/stock/bar"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// NewRepository returns a bar repository over the fake market and its calendar, in db
+func NewRepository(t testing.TB, db *gorm.DB, market *fake.Market) *bar.Repository {
+	t.Helper()
+
+	repository, err := bar.NewRepository(db, market, testutil.CalendarRepository(t, db, market), testutil.FeedSelector())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repository
+}
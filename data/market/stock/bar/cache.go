@@ -0,0 +1,424 @@
+package bar
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"time"
+)
+
+// settlePeriod is how long after a session closes its bars are considered final
+const settlePeriod = 30 * time.Minute
+
+// CachedBar is a historical bar stored by the Cache
+type CachedBar struct {
+	Symbol     string `gorm:"primaryKey"`
+	TimeFrame  string `gorm:"primaryKey"`
+	Adjustment string `gorm:"primaryKey"`
+	Feed       string `gorm:"primaryKey"`
+	// Time is the bar's timestamp in unix seconds
+	Time       int64 `gorm:"primaryKey;autoIncrement:false"`
+	Open       float64
+	High       float64
+	Low        float64
+	Close      float64
+	Volume     uint64
+	TradeCount uint64
+	VWAP       float64
+}
+
+// CachedSession records a trading day whose bars have all been stored by the Cache
+type CachedSession struct {
+	Symbol     string `gorm:"primaryKey"`
+	TimeFrame  string `gorm:"primaryKey"`
+	Adjustment string `gorm:"primaryKey"`
+	Feed       string `gorm:"primaryKey"`
+	Date       string `gorm:"primaryKey"`
+	FetchedAt  time.Time
+}
+
+type cacheKey struct {
+	symbol     string
+	timeFrame  string
+	adjustment string
+	feed       string
+}
+
+func newCacheKey(symbol string, request marketdata.GetBarsRequest) cacheKey {
+	adjustment := request.Adjustment
+
+	if adjustment == "" {
+		adjustment = marketdata.Raw
+	}
+
+	return cacheKey{
+		symbol:     symbol,
+		timeFrame:  request.TimeFrame.String(),
+		adjustment: string(adjustment),
+		feed:       string(request.Feed),
+	}
+}
+
+func (k cacheKey) where(db *gorm.DB) *gorm.DB {
+	return db.Where("symbol = ? AND time_frame = ? AND adjustment = ? AND feed = ?", k.symbol, k.timeFrame, k.adjustment, k.feed)
+}
+
+type fetcher func(request marketdata.GetBarsRequest) ([]marketdata.Bar, error)
+
+// Cache stores historical bars in the database, only requesting the trading days it does not hold. Trading days
+// that have not settled yet are requested every time.
+type Cache struct {
+	db                 *gorm.DB
+	calendarRepository *calendar.Repository
+}
+
+func NewCache(db *gorm.DB, calendarRepository *calendar.Repository) (*Cache, error) {
+	err := db.AutoMigrate(&CachedBar{}, &CachedSession{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		db:                 db,
+		calendarRepository: calendarRepository,
+	}, nil
+}
+
+// cacheable returns true if every bar of the request falls within a single trading day
+func cacheable(request marketdata.GetBarsRequest) bool {
+	switch request.TimeFrame.Unit {
+	case marketdata.Min, marketdata.Hour:
+	case marketdata.Day:
+		if request.TimeFrame.N != 1 {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return !request.Start.IsZero() && request.TotalLimit == 0 && request.AsOf == "" && request.Currency == ""
+}
+
+func toDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+func startOfDate(date string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02", date, newYork)
+
+	return t
+}
+
+func endOfDate(date string) time.Time {
+	return startOfDate(date).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// Bars returns the symbol's bars for the request, fetching the trading days missing from the cache
+func (c *Cache) Bars(symbol string, request marketdata.GetBarsRequest, fetch fetcher) ([]marketdata.Bar, error) {
+	if !cacheable(request) {
+		return fetch(request)
+	}
+
+	if now := time.Now(); request.End.IsZero() || request.End.After(now) {
+		request.End = now
+	}
+
+	var bars []marketdata.Bar
+
+	// the calendar only covers recent years, older bars are requested directly
+	earliest, err := c.calendarRepository.Earliest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if toDate(request.Start) < earliest.Date {
+		older := request
+		older.End = startOfDate(earliest.Date).Add(-time.Nanosecond)
+
+		if older.End.After(request.End) {
+			older.End = request.End
+		}
+
+		bars, err = fetch(older)
+
+		if err != nil {
+			return nil, err
+		}
+
+		request.Start = startOfDate(earliest.Date)
+
+		if request.Start.After(request.End) {
+			return bars, nil
+		}
+	}
+
+	key := newCacheKey(symbol, request)
+
+	err = c.fill(key, request, fetch, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := c.load(key, request.Start, request.End)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(bars, cached...), nil
+}
+
+// fill fetches the runs of consecutive trading days in the request that are not cached. When the bars are adjusted,
+// the last cached day before each run is fetched again; if it differs, the adjustment has changed and the symbol's
+// adjusted bars are invalidated and fetched again.
+func (c *Cache) fill(key cacheKey, request marketdata.GetBarsRequest, fetch fetcher, revalidate bool) error {
+	sessions, err := c.calendarRepository.Between(toDate(request.Start), toDate(request.End))
+
+	if err != nil {
+		return err
+	}
+
+	cachedDates, err := c.cachedDates(key, toDate(request.Start), toDate(request.End))
+
+	if err != nil {
+		return err
+	}
+
+	var run []calendar.Calendar
+
+	for i, session := range sessions {
+		if !cachedDates[session.Date] {
+			run = append(run, session)
+		}
+
+		if len(run) == 0 || (i < len(sessions)-1 && !cachedDates[sessions[i+1].Date]) {
+			continue
+		}
+
+		changed, err := c.fetchRun(key, request, run, fetch)
+
+		if err != nil {
+			return err
+		}
+
+		if changed && revalidate {
+			err = c.invalidateAdjusted(key.symbol)
+
+			if err != nil {
+				return err
+			}
+
+			return c.fill(key, request, fetch, false)
+		}
+
+		run = nil
+	}
+
+	return nil
+}
+
+// fetchRun fetches and stores the bars of the consecutive trading days, returning true if the adjusted prices of
+// the cached day before them have changed
+func (c *Cache) fetchRun(key cacheKey, request marketdata.GetBarsRequest, run []calendar.Calendar, fetch fetcher) (bool, error) {
+	now := time.Now()
+	runRequest := request
+	runRequest.Start = startOfDate(run[0].Date)
+	runRequest.End = endOfDate(run[len(run)-1].Date)
+
+	if runRequest.End.After(now) {
+		runRequest.End = now
+	}
+
+	var anchor string
+
+	if key.adjustment != string(marketdata.Raw) {
+		var session CachedSession
+
+		result := key.where(c.db.Model(&CachedSession{})).
+			Where("date < ?", run[0].Date).
+			Order("date desc").
+			Limit(1).
+			Find(&session)
+
+		if result.Error != nil {
+			return false, result.Error
+		}
+
+		if session.Date != "" {
+			anchor = session.Date
+			runRequest.Start = startOfDate(anchor)
+		}
+	}
+
+	bars, err := fetch(runRequest)
+
+	if err != nil {
+		return false, err
+	}
+
+	if anchor != "" {
+		changed, err := c.changed(key, anchor, bars)
+
+		if err != nil || changed {
+			return changed, err
+		}
+	}
+
+	err = c.store(key, bars)
+
+	if err != nil {
+		return false, err
+	}
+
+	var settled []CachedSession
+
+	for _, session := range run {
+		if now.After(session.SessionClose.Add(settlePeriod)) {
+			settled = append(settled, CachedSession{
+				Symbol:     key.symbol,
+				TimeFrame:  key.timeFrame,
+				Adjustment: key.adjustment,
+				Feed:       key.feed,
+				Date:       session.Date,
+				FetchedAt:  now,
+			})
+		}
+	}
+
+	if len(settled) == 0 {
+		return false, nil
+	}
+
+	return false, c.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(settled, 200).Error
+}
+
+// changed returns true if the fetched bars of the date differ from the cached bars
+func (c *Cache) changed(key cacheKey, date string, bars []marketdata.Bar) (bool, error) {
+	cached, err := c.load(key, startOfDate(date), endOfDate(date))
+
+	if err != nil {
+		return false, err
+	}
+
+	closes := make(map[int64]float64, len(cached))
+
+	for _, bar := range cached {
+		closes[bar.Timestamp.Unix()] = bar.Close
+	}
+
+	for _, bar := range bars {
+		if toDate(bar.Timestamp) != date {
+			continue
+		}
+
+		if cachedClose, ok := closes[bar.Timestamp.Unix()]; ok && math.Abs(cachedClose-bar.Close) > 1e-6*math.Max(1, bar.Close) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Cache) cachedDates(key cacheKey, start, end string) (map[string]bool, error) {
+	var dates []string
+
+	result := key.where(c.db.Model(&CachedSession{})).
+		Where("date >= ? AND date <= ?", start, end).
+		Pluck("date", &dates)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	cachedDates := make(map[string]bool, len(dates))
+
+	for _, date := range dates {
+		cachedDates[date] = true
+	}
+
+	return cachedDates, nil
+}
+
+func (c *Cache) store(key cacheKey, bars []marketdata.Bar) error {
+	if len(bars) == 0 {
+		return nil
+	}
+
+	rows := make([]CachedBar, len(bars))
+
+	for i, bar := range bars {
+		rows[i] = CachedBar{
+			Symbol:     key.symbol,
+			TimeFrame:  key.timeFrame,
+			Adjustment: key.adjustment,
+			Feed:       key.feed,
+			Time:       bar.Timestamp.Unix(),
+			Open:       bar.Open,
+			High:       bar.High,
+			Low:        bar.Low,
+			Close:      bar.Close,
+			Volume:     bar.Volume,
+			TradeCount: bar.TradeCount,
+			VWAP:       bar.VWAP,
+		}
+	}
+
+	return c.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rows, 200).Error
+}
+
+func (c *Cache) load(key cacheKey, start, end time.Time) ([]marketdata.Bar, error) {
+	var rows []CachedBar
+
+	result := key.where(c.db).
+		Where("time >= ? AND time <= ?", start.Unix(), end.Unix()).
+		Order("time asc").
+		Find(&rows)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	bars := make([]marketdata.Bar, len(rows))
+
+	for i, row := range rows {
+		bars[i] = marketdata.Bar{
+			Timestamp:  time.Unix(row.Time, 0).UTC(),
+			Open:       row.Open,
+			High:       row.High,
+			Low:        row.Low,
+			Close:      row.Close,
+			Volume:     row.Volume,
+			TradeCount: row.TradeCount,
+			VWAP:       row.VWAP,
+		}
+	}
+
+	return bars, nil
+}
+
+// invalidateAdjusted removes the symbol's split and dividend adjusted bars, which change with every split
+func (c *Cache) invalidateAdjusted(symbol string) error {
+	err := c.db.Where("symbol = ? AND adjustment <> ?", symbol, marketdata.Raw).Delete(&CachedBar{}).Error
+
+	if err != nil {
+		return err
+	}
+
+	return c.db.Where("symbol = ? AND adjustment <> ?", symbol, marketdata.Raw).Delete(&CachedSession{}).Error
+}
+
+// Invalidate removes every cached bar of the symbol
+func (c *Cache) Invalidate(symbol string) error {
+	err := c.db.Where("symbol = ?", symbol).Delete(&CachedBar{}).Error
+
+	if err != nil {
+		return err
+	}
+
+	return c.db.Where("symbol = ?", symbol).Delete(&CachedSession{}).Error
+}
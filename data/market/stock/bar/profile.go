@@ -0,0 +1,118 @@
+package bar
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"time"
+)
+
+// MaxProfileDays is the most trading days averaged by a volume profile
+const MaxProfileDays = 60
+
+// VolumeProfile is a symbol's average cumulative volume by time of day over the trading days before Date.
+// Cumulative[m] is the average volume traded from the session open to the end of the session's minute m, averaged
+// over the days whose session reached that minute, so that early closes do not drag the later minutes down.
+type VolumeProfile struct {
+	Symbol       string    `json:"symbol"`
+	Date         string    `json:"date"`
+	SessionOpen  time.Time `json:"sessionOpen"`
+	SessionClose time.Time `json:"sessionClose"`
+	Dates        []string  `json:"dates"`
+	Cumulative   []float64 `json:"cumulative"`
+}
+
+// Minute returns the index of the session minute containing t, if the session covers it
+func (p *VolumeProfile) Minute(t time.Time) (int, bool) {
+	if t.Before(p.SessionOpen) || !t.Before(p.SessionClose) {
+		return 0, false
+	}
+
+	return int(t.Sub(p.SessionOpen) / time.Minute), true
+}
+
+// Average returns the average cumulative volume at the end of the session minute containing t
+func (p *VolumeProfile) Average(t time.Time) float64 {
+	m, ok := p.Minute(t)
+
+	if !ok || m >= len(p.Cumulative) {
+		return 0
+	}
+
+	return p.Cumulative[m]
+}
+
+// VolumeProfile returns the symbol's volume profile for the current trading day, or the previous one if today is not
+// a trading day, from the split adjusted minute bars of the preceding number of trading days
+func (b *Repository) VolumeProfile(symbol string, days int) (*VolumeProfile, error) {
+	if days < 1 {
+		days = 1
+	}
+
+	if days > MaxProfileDays {
+		days = MaxProfileDays
+	}
+
+	tradingDays, err := b.latestSessions(days + 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	day := tradingDays[len(tradingDays)-1]
+	history := tradingDays[:len(tradingDays)-1]
+	minutes := int(day.SessionClose.Sub(day.SessionOpen) / time.Minute)
+
+	profile := &VolumeProfile{
+		Symbol:       symbol,
+		Date:         day.Date,
+		SessionOpen:  day.SessionOpen,
+		SessionClose: day.SessionClose,
+		Dates:        make([]string, 0, len(history)),
+		Cumulative:   make([]float64, minutes),
+	}
+
+	if len(history) == 0 {
+		return profile, nil
+	}
+
+	bars, err := b.Bars(symbol, marketdata.OneMin, history[0].SessionOpen, history[len(history)-1].SessionClose, marketdata.Split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make([]float64, minutes)
+	counts := make([]int, minutes)
+	i := 0
+
+	for _, past := range history {
+		profile.Dates = append(profile.Dates, past.Date)
+		length := int(past.SessionClose.Sub(past.SessionOpen) / time.Minute)
+		var cumulative uint64
+
+		for m := 0; m < length && m < minutes; m++ {
+			end := past.SessionOpen.Add(time.Duration(m+1) * time.Minute)
+
+			// bars before the session, such as the previous day's overnight bars, are skipped
+			for ; i < len(bars) && bars[i].Timestamp.Before(end); i++ {
+				if !bars[i].Timestamp.Before(past.SessionOpen) {
+					cumulative += bars[i].Volume
+				}
+			}
+
+			totals[m] += float64(cumulative)
+			counts[m]++
+		}
+	}
+
+	for m := range profile.Cumulative {
+		switch {
+		case counts[m] > 0:
+			profile.Cumulative[m] = totals[m] / float64(counts[m])
+		case m > 0:
+			// no earlier session lasted this long
+			profile.Cumulative[m] = profile.Cumulative[m-1]
+		}
+	}
+
+	return profile, nil
+}
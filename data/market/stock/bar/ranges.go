@@ -0,0 +1,244 @@
+package bar
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preset is a charting range ending with the latest trading session
+type Preset string
+
+const (
+	OneDay      Preset = "1D"
+	FiveDays    Preset = "5D"
+	OneMonth    Preset = "1M"
+	ThreeMonths Preset = "3M"
+	SixMonths   Preset = "6M"
+	YearToDate  Preset = "YTD"
+	OneYear     Preset = "1Y"
+	FiveYears   Preset = "5Y"
+	Max         Preset = "Max"
+)
+
+// Presets are every preset, shortest first
+var Presets = []Preset{OneDay, FiveDays, OneMonth, ThreeMonths, SixMonths, YearToDate, OneYear, FiveYears, Max}
+
+// maxStart is the earliest date requested by the Max preset, before any bars are available
+var maxStart = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrInvalidTimeFrame  = errors.New("invalid timeframe")
+	ErrInvalidAdjustment = errors.New("invalid adjustment")
+	ErrInvalidPreset     = errors.New("invalid preset")
+	ErrNoSessions        = errors.New("no trading sessions found")
+)
+
+// Range is a preset's bars along with the timeframe and period chosen for it
+type Range struct {
+	Symbol    string           `json:"symbol"`
+	Preset    Preset           `json:"preset"`
+	TimeFrame string           `json:"timeFrame"`
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Bars      []marketdata.Bar `json:"bars"`
+}
+
+var timeFramePattern = regexp.MustCompile(`^(\d+)(min|t|hour|h|day|d|week|w|month)$`)
+
+// ParseTimeFrame parses a timeframe such as "1Min", "15Min", "1Hour", "1Day", "1Week" or "3Month"
+func ParseTimeFrame(s string) (marketdata.TimeFrame, error) {
+	match := timeFramePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+
+	if match == nil {
+		return marketdata.TimeFrame{}, fmt.Errorf("%w %q", ErrInvalidTimeFrame, s)
+	}
+
+	n, _ := strconv.Atoi(match[1])
+
+	var timeFrame marketdata.TimeFrame
+
+	switch match[2] {
+	case "min", "t":
+		timeFrame = marketdata.NewTimeFrame(n, marketdata.Min)
+	case "hour", "h":
+		timeFrame = marketdata.NewTimeFrame(n, marketdata.Hour)
+	case "day", "d":
+		timeFrame = marketdata.NewTimeFrame(n, marketdata.Day)
+	case "week", "w":
+		timeFrame = marketdata.NewTimeFrame(n, marketdata.Week)
+	default:
+		timeFrame = marketdata.NewTimeFrame(n, marketdata.Month)
+	}
+
+	// the ranges accepted by the bars endpoint
+	valid := false
+
+	switch timeFrame.Unit {
+	case marketdata.Min:
+		valid = n >= 1 && n <= 59
+	case marketdata.Hour:
+		valid = n >= 1 && n <= 23
+	case marketdata.Day, marketdata.Week:
+		valid = n == 1
+	case marketdata.Month:
+		valid = n == 1 || n == 2 || n == 3 || n == 4 || n == 6 || n == 12
+	}
+
+	if !valid {
+		return marketdata.TimeFrame{}, fmt.Errorf("%w %q", ErrInvalidTimeFrame, s)
+	}
+
+	return timeFrame, nil
+}
+
+// ParseAdjustment parses "raw", "split", "dividend" or "all", defaulting to split when empty
+func ParseAdjustment(s string) (marketdata.Adjustment, error) {
+	switch adjustment := marketdata.Adjustment(strings.ToLower(strings.TrimSpace(s))); adjustment {
+	case "":
+		return marketdata.Split, nil
+	case marketdata.Raw, marketdata.Split, marketdata.Dividend, marketdata.All:
+		return adjustment, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidAdjustment, s)
+	}
+}
+
+// ParsePreset parses a preset such as "5D" or "ytd"
+func ParsePreset(s string) (Preset, error) {
+	for _, preset := range Presets {
+		if strings.EqualFold(string(preset), strings.TrimSpace(s)) {
+			return preset, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w %q", ErrInvalidPreset, s)
+}
+
+// Bars returns the symbol's bars for the timeframe from start to end. A zero end is now.
+func (b *Repository) Bars(symbol string, timeFrame marketdata.TimeFrame, start, end time.Time, adjustment marketdata.Adjustment) ([]marketdata.Bar, error) {
+	return b.getBars(symbol, marketdata.GetBarsRequest{
+		TimeFrame:  timeFrame,
+		Adjustment: adjustment,
+		Start:      start,
+		End:        end,
+	})
+}
+
+// latestSessions returns up to n trading days ending with the current trading day, or the previous one if today
+// is not a trading day, oldest first
+func (b *Repository) latestSessions(n int) ([]calendar.Calendar, error) {
+	now := time.Now()
+
+	// allow for weekends and holidays
+	sessions, err := b.calendarRepository.Between(toDate(now.AddDate(0, 0, -(n*7/5+14))), toDate(now))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return nil, ErrNoSessions
+	}
+
+	if len(sessions) > n {
+		sessions = sessions[len(sessions)-n:]
+	}
+
+	return sessions, nil
+}
+
+// firstSessionFrom returns the start of the first trading day on or after the date, or the start of the date
+// itself if the calendar does not cover it
+func (b *Repository) firstSessionFrom(date time.Time, latest *calendar.Calendar) (time.Time, error) {
+	earliest, err := b.calendarRepository.Earliest()
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if toDate(date) < earliest.Date {
+		return startOfDate(toDate(date)), nil
+	}
+
+	sessions, err := b.calendarRepository.Between(toDate(date), latest.Date)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(sessions) == 0 {
+		return startOfDate(toDate(date)), nil
+	}
+
+	return startOfDate(sessions[0].Date), nil
+}
+
+// Preset returns the symbol's bars for the preset, split adjusted, choosing a timeframe that suits its length:
+// minute bars for 1D, 5 minute bars for 5D, hourly bars for 1M, daily bars up to 1Y, weekly bars for 5Y and
+// monthly bars for Max
+func (b *Repository) Preset(symbol string, preset Preset) (*Range, error) {
+	sessions, err := b.latestSessions(5)
+
+	if err != nil {
+		return nil, err
+	}
+
+	latest := &sessions[len(sessions)-1]
+
+	r := &Range{
+		Symbol: symbol,
+		Preset: preset,
+		End:    time.Now(),
+	}
+
+	latestDate := startOfDate(latest.Date)
+	timeFrame := marketdata.OneDay
+
+	switch preset {
+	case OneDay:
+		timeFrame = marketdata.OneMin
+		r.Start = latest.SessionOpen
+		r.End = latest.SessionClose
+	case FiveDays:
+		timeFrame = marketdata.NewTimeFrame(5, marketdata.Min)
+		r.Start = sessions[0].SessionOpen
+	case OneMonth:
+		timeFrame = marketdata.OneHour
+		r.Start, err = b.firstSessionFrom(latestDate.AddDate(0, -1, 0), latest)
+	case ThreeMonths:
+		r.Start, err = b.firstSessionFrom(latestDate.AddDate(0, -3, 0), latest)
+	case SixMonths:
+		r.Start, err = b.firstSessionFrom(latestDate.AddDate(0, -6, 0), latest)
+	case YearToDate:
+		r.Start, err = b.firstSessionFrom(time.Date(latestDate.Year(), time.January, 1, 0, 0, 0, 0, newYork), latest)
+	case OneYear:
+		r.Start, err = b.firstSessionFrom(latestDate.AddDate(-1, 0, 0), latest)
+	case FiveYears:
+		timeFrame = marketdata.OneWeek
+		r.Start, err = b.firstSessionFrom(latestDate.AddDate(-5, 0, 0), latest)
+	case Max:
+		timeFrame = marketdata.OneMonth
+		r.Start = maxStart
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidPreset, preset)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.TimeFrame = timeFrame.String()
+	r.Bars, err = b.Bars(symbol, timeFrame, r.Start, r.End, marketdata.Split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -2,73 +2,87 @@ package bar
 
 import (
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
-	"github.com/golang-module/carbon/v2"
+	"github.com/phoobynet/buffalo/data/market/feed"
+	"github.com/phoobynet/buffalo/data/market/source"
 	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"gorm.io/gorm"
 )
 
 // Repository provides access to market data bars information
 type Repository struct {
-	marketDataClient   *marketdata.Client
+	marketDataClient   source.HistoricalSource
 	calendarRepository *calendar.Repository
+	feedSelector       *feed.Selector
+	cache              *Cache
 }
 
-func NewRepository(marketDataClient *marketdata.Client, calendarRepository *calendar.Repository) (*Repository, error) {
+func NewRepository(db *gorm.DB, marketDataClient source.HistoricalSource, calendarRepository *calendar.Repository, feedSelector *feed.Selector) (*Repository, error) {
+	cache, err := NewCache(db, calendarRepository)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &Repository{
 		marketDataClient:   marketDataClient,
 		calendarRepository: calendarRepository,
+		feedSelector:       feedSelector,
+		cache:              cache,
 	}, nil
 }
 
-// Intraday returns the intraday bars for the given symbol for either the current or previous day
+// getBars returns cached bars, requesting missing bars from the selected feed and falling back to IEX if SIP is
+// rejected
+func (b *Repository) getBars(symbol string, request marketdata.GetBarsRequest) ([]marketdata.Bar, error) {
+	var bars []marketdata.Bar
+
+	err := b.feedSelector.Do(func(f marketdata.Feed) error {
+		var err error
+		request.Feed = f
+		bars, err = b.cache.Bars(symbol, request, func(request marketdata.GetBarsRequest) ([]marketdata.Bar, error) {
+			return b.marketDataClient.GetBars(symbol, request)
+		})
+
+		return err
+	})
+
+	return bars, err
+}
+
+// Invalidate removes the symbol's cached bars
+func (b *Repository) Invalidate(symbol string) error {
+	return b.cache.Invalidate(symbol)
+}
+
+// Intraday returns the minute bars for the given symbol for either the current or previous trading day
 func (b *Repository) Intraday(symbol string) ([]marketdata.Bar, error) {
-	currentCalendar, err := b.calendarRepository.CurrentCalendar()
+	r, err := b.Preset(symbol, OneDay)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if currentCalendar == nil {
-		currentCalendar, err = b.calendarRepository.PreviousCalendar()
-
-		if err != nil {
-			return nil, err
-		}
-	}
+	return r.Bars, nil
+}
 
-	bars, err := b.marketDataClient.GetBars(symbol, marketdata.GetBarsRequest{
-		TimeFrame: marketdata.TimeFrame{
-			N:    1,
-			Unit: marketdata.Min,
-		},
-		Adjustment: marketdata.Split,
-		Start:      currentCalendar.SessionOpen,
-		End:        currentCalendar.SessionClose,
-		Feed:       marketdata.SIP,
-	})
+// LatestSession returns the date of the current or, outside of a trading day, the previous trading day
+func (b *Repository) LatestSession() (string, error) {
+	sessions, err := b.latestSessions(1)
 
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return bars, nil
+	return sessions[0].Date, nil
 }
 
 // YTD returns the year-to-date bars for the given symbol with a daily interval
 func (b *Repository) YTD(symbol string) ([]marketdata.Bar, error) {
-	bars, err := b.marketDataClient.GetBars(symbol, marketdata.GetBarsRequest{
-		TimeFrame: marketdata.TimeFrame{
-			N:    1,
-			Unit: marketdata.Day,
-		},
-		Adjustment: marketdata.Split,
-		Start:      carbon.Now().SubYears(1).SubDays(1).ToStdTime(),
-		End:        carbon.Now().StartOfDay().ToStdTime(),
-		Feed:       marketdata.SIP,
-	})
+	r, err := b.Preset(symbol, YearToDate)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return bars, nil
+	return r.Bars, nil
 }
@@ -0,0 +1,82 @@
+package bar
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"testing"
+	"time"
+)
+
+// newFakeRepository returns a repository over the fake market, with its calendar, in a temporary database
+func newFakeRepository(t *testing.T, market *fake.Market) *Repository {
+	t.Helper()
+
+	db := testutil.DB(t)
+	repository, err := NewRepository(db, market, testutil.CalendarRepository(t, db, market), testutil.FeedSelector())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repository
+}
+
+func TestRepositoryBarsFromFakeMarket(t *testing.T) {
+	now := time.Date(2026, 10, 16, 11, 0, 0, 0, newYork)
+	market := fake.NewMarket(fake.Options{Now: func() time.Time { return now }})
+	repository := newFakeRepository(t, market)
+
+	start := time.Date(2026, 10, 12, 0, 0, 0, 0, newYork)
+	end := time.Date(2026, 10, 15, 23, 59, 0, 0, newYork)
+
+	expected, err := market.GetBars("AAPL", marketdata.GetBarsRequest{TimeFrame: marketdata.OneHour, Start: start, End: end})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second request is served from the cache
+	for attempt := 1; attempt <= 2; attempt++ {
+		bars, err := repository.Bars("AAPL", marketdata.OneHour, start, end, marketdata.Split)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(bars) == 0 || len(bars) != len(expected) {
+			t.Fatalf("attempt %d: expected %d bars, got %d", attempt, len(expected), len(bars))
+		}
+
+		for i := range bars {
+			if !bars[i].Timestamp.Equal(expected[i].Timestamp) || bars[i].Close != expected[i].Close || bars[i].Volume != expected[i].Volume {
+				t.Fatalf("attempt %d: bar %d is %+v, expected %+v", attempt, i, bars[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestRepositorySessionsFromFakeCalendar(t *testing.T) {
+	market := fake.NewMarket(fake.Options{})
+	repository := newFakeRepository(t, market)
+
+	sessions, err := repository.Sessions("MSFT", 3, marketdata.NewTimeFrame(30, marketdata.Min))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions.Sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(sessions.Sessions))
+	}
+
+	for _, session := range sessions.Sessions {
+		if weekday := session.Open.In(newYork).Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+			t.Fatalf("session %s is on a weekend", session.Date)
+		}
+
+		if len(session.Segments) != 3 {
+			t.Fatalf("session %s has %d segments", session.Date, len(session.Segments))
+		}
+	}
+}
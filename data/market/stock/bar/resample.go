@@ -0,0 +1,306 @@
+package bar
+
+import (
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResampleOptions describes the bars produced by Resample
+type ResampleOptions struct {
+	TimeFrame marketdata.TimeFrame
+	// RegularHoursOnly drops pre-market and post-market bars
+	RegularHoursOnly bool
+	// AnchorToOpen aligns intraday bars with the regular open, e.g. 9:30, 10:30, rather than the hour. Post-market
+	// bars are aligned with the close.
+	AnchorToOpen bool
+}
+
+// accumulator combines bars into one, weighting the VWAP by volume
+type accumulator struct {
+	bar      marketdata.Bar
+	notional float64
+	empty    bool
+}
+
+func (a *accumulator) reset(timestamp time.Time) {
+	a.bar = marketdata.Bar{Timestamp: timestamp}
+	a.notional = 0
+	a.empty = true
+}
+
+func (a *accumulator) add(bar marketdata.Bar) {
+	if a.empty {
+		a.bar.Open = bar.Open
+		a.bar.High = bar.High
+		a.bar.Low = bar.Low
+		a.empty = false
+	}
+
+	if bar.High > a.bar.High {
+		a.bar.High = bar.High
+	}
+
+	if bar.Low < a.bar.Low {
+		a.bar.Low = bar.Low
+	}
+
+	a.bar.Close = bar.Close
+	a.bar.Volume += bar.Volume
+	a.bar.TradeCount += bar.TradeCount
+	a.notional += bar.VWAP * float64(bar.Volume)
+}
+
+func (a *accumulator) result() marketdata.Bar {
+	bar := a.bar
+
+	if bar.Volume > 0 {
+		bar.VWAP = a.notional / float64(bar.Volume)
+	}
+
+	return bar
+}
+
+func duration(timeFrame marketdata.TimeFrame) time.Duration {
+	if timeFrame.Unit == marketdata.Hour {
+		return time.Duration(timeFrame.N) * time.Hour
+	}
+
+	return time.Duration(timeFrame.N) * time.Minute
+}
+
+// floorTo returns the start of the interval of length d, counted from the anchor, that contains t
+func floorTo(t, anchor time.Time, d time.Duration) time.Time {
+	n := t.Sub(anchor) / d
+
+	if t.Before(anchor) && t.Sub(anchor)%d != 0 {
+		n--
+	}
+
+	return anchor.Add(n * d)
+}
+
+// intradayBucket returns the start of the intraday bar that the bar starting at t belongs to. Bars never span
+// the boundaries between the pre-market, regular and post-market segments.
+func intradayBucket(t time.Time, day calendar.Calendar, options ResampleOptions) (time.Time, bool) {
+	var start, anchor time.Time
+
+	switch {
+	case t.Before(day.SessionOpen) || !t.Before(day.SessionClose):
+		return time.Time{}, false
+	case t.Before(day.Open):
+		start, anchor = day.SessionOpen, day.Open
+	case t.Before(day.Close):
+		start, anchor = day.Open, day.Open
+	default:
+		start, anchor = day.Close, day.Close
+	}
+
+	if options.RegularHoursOnly && start != day.Open {
+		return time.Time{}, false
+	}
+
+	if !options.AnchorToOpen {
+		anchor = startOfDate(day.Date)
+	}
+
+	bucket := floorTo(t, anchor, duration(options.TimeFrame))
+
+	if bucket.Before(start) {
+		bucket = start
+	}
+
+	return bucket, true
+}
+
+// periodKey identifies the day, week or month the date belongs to
+func periodKey(date string, unit marketdata.TimeFrameUnit) string {
+	switch unit {
+	case marketdata.Week:
+		year, week := startOfDate(date).ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case marketdata.Month:
+		return date[:7]
+	default:
+		return date
+	}
+}
+
+// Resample combines bars sorted by timestamp into bars of the options' timeframe, using the trading days to find
+// the regular and extended hours. Daily, weekly and monthly bars are timestamped at the start of their first
+// trading day. Daily input bars can only be combined into daily, weekly or monthly bars.
+func Resample(bars []marketdata.Bar, tradingDays []calendar.Calendar, options ResampleOptions) []marketdata.Bar {
+	days := make(map[string]calendar.Calendar, len(tradingDays))
+
+	for _, day := range tradingDays {
+		days[day.Date] = day
+	}
+
+	intraday := options.TimeFrame.Unit == marketdata.Min || options.TimeFrame.Unit == marketdata.Hour
+	resampled := make([]marketdata.Bar, 0)
+
+	var (
+		current accumulator
+		key     string
+	)
+
+	current.empty = true
+
+	for _, bar := range bars {
+		date := toDate(bar.Timestamp)
+		day, known := days[date]
+
+		var (
+			barKey    string
+			timestamp time.Time
+		)
+
+		if intraday {
+			if !known {
+				continue
+			}
+
+			bucket, ok := intradayBucket(bar.Timestamp, day, options)
+
+			if !ok {
+				continue
+			}
+
+			barKey, timestamp = bucket.String(), bucket
+		} else {
+			// intraday input bars are filtered by segment, daily input bars start before the session
+			if known && !bar.Timestamp.Before(day.SessionOpen) {
+				if !bar.Timestamp.Before(day.SessionClose) {
+					continue
+				}
+
+				if options.RegularHoursOnly && (bar.Timestamp.Before(day.Open) || !bar.Timestamp.Before(day.Close)) {
+					continue
+				}
+			}
+
+			barKey, timestamp = periodKey(date, options.TimeFrame.Unit), startOfDate(date)
+		}
+
+		if barKey != key {
+			if !current.empty {
+				resampled = append(resampled, current.result())
+			}
+
+			key = barKey
+			current.reset(timestamp)
+		}
+
+		current.add(bar)
+	}
+
+	if !current.empty {
+		resampled = append(resampled, current.result())
+	}
+
+	return resampled
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// resampleSource returns the coarsest timeframe whose bars can be combined into the options' timeframe without
+// crossing a segment boundary. Regular hours start and end on the half hour.
+func resampleSource(options ResampleOptions) marketdata.TimeFrame {
+	switch options.TimeFrame.Unit {
+	case marketdata.Min:
+		return marketdata.NewTimeFrame(gcd(options.TimeFrame.N, 30), marketdata.Min)
+	case marketdata.Hour:
+		return marketdata.NewTimeFrame(30, marketdata.Min)
+	default:
+		if options.RegularHoursOnly {
+			return marketdata.NewTimeFrame(30, marketdata.Min)
+		}
+
+		return marketdata.OneDay
+	}
+}
+
+// Resample returns the symbol's split adjusted bars from start to end resampled according to the options. The
+// cached history is combined with the symbol's live intraday series once it has been seeded.
+func (s *SeriesStore) Resample(symbol string, start, end time.Time, options ResampleOptions) ([]marketdata.Bar, error) {
+	if options.TimeFrame.Unit != marketdata.Min && options.TimeFrame.Unit != marketdata.Hour && options.TimeFrame.N != 1 {
+		return nil, fmt.Errorf("%w %q", ErrInvalidTimeFrame, options.TimeFrame)
+	}
+
+	symbol = strings.ToUpper(symbol)
+
+	if end.IsZero() || end.After(time.Now()) {
+		end = time.Now()
+	}
+
+	source := resampleSource(options)
+
+	bars, err := s.repository.Bars(symbol, source, start, end, marketdata.Split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tradingDays, err := s.repository.calendarRepository.Between(toDate(start), toDate(end))
+
+	if err != nil {
+		return nil, err
+	}
+
+	bars = s.withLive(symbol, bars, source, start, end)
+
+	return Resample(bars, tradingDays, options), nil
+}
+
+// withLive replaces the bars of the symbol's live session with the live series
+func (s *SeriesStore) withLive(symbol string, bars []marketdata.Bar, source marketdata.TimeFrame, start, end time.Time) []marketdata.Bar {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	current, ok := s.series[symbol]
+
+	if !ok || !current.seeded || current.date == "" {
+		return bars
+	}
+
+	var live []marketdata.Bar
+
+	if source.Unit == marketdata.Day {
+		if current.dailyBar == nil || toDate(current.dailyBar.Timestamp) != current.date {
+			return bars
+		}
+
+		live = []marketdata.Bar{*current.dailyBar}
+	} else {
+		live = current.bars
+	}
+
+	merged := make([]marketdata.Bar, 0, len(bars)+len(live))
+
+	for _, bar := range bars {
+		if toDate(bar.Timestamp) != current.date {
+			merged = append(merged, bar)
+		}
+	}
+
+	for _, bar := range live {
+		if !bar.Timestamp.Before(start) && !bar.Timestamp.After(end) {
+			merged = append(merged, bar)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+
+	return merged
+}
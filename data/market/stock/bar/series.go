@@ -0,0 +1,230 @@
+package bar
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpdateKind describes how a streamed bar changed an intraday series
+type UpdateKind string
+
+const (
+	Appended UpdateKind = "append"
+	Replaced UpdateKind = "replace"
+	Daily    UpdateKind = "daily"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// Update is an incremental change to a symbol's intraday series
+type Update struct {
+	Symbol string         `json:"symbol"`
+	Kind   UpdateKind     `json:"kind"`
+	Index  int            `json:"index"`
+	Bar    marketdata.Bar `json:"bar"`
+}
+
+type series struct {
+	date     string
+	seeded   bool
+	session  string
+	bars     []marketdata.Bar
+	dailyBar *marketdata.Bar
+}
+
+// SeriesStore maintains the intraday minute bars of every streamed symbol, seeded from the Repository and kept
+// current by the minute, updated and daily bar streams. A series is seeded again once the latest session changes.
+type SeriesStore struct {
+	mut        sync.RWMutex
+	repository *Repository
+	series     map[string]*series
+}
+
+func NewSeriesStore(repository *Repository) *SeriesStore {
+	return &SeriesStore{
+		repository: repository,
+		series:     make(map[string]*series),
+	}
+}
+
+func ToBar(streamBar stream.Bar) marketdata.Bar {
+	return marketdata.Bar{
+		Timestamp:  streamBar.Timestamp,
+		Open:       streamBar.Open,
+		High:       streamBar.High,
+		Low:        streamBar.Low,
+		Close:      streamBar.Close,
+		Volume:     streamBar.Volume,
+		TradeCount: streamBar.TradeCount,
+		VWAP:       streamBar.VWAP,
+	}
+}
+
+func sessionDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+// get returns the symbol's series, starting a new one when a bar from a later session arrives
+func (s *SeriesStore) get(symbol string, timestamp time.Time) *series {
+	date := sessionDate(timestamp)
+	current, ok := s.series[symbol]
+
+	if !ok || current.date < date {
+		current = &series{date: date}
+		s.series[symbol] = current
+	}
+
+	return current
+}
+
+// merge inserts the bar in timestamp order, replacing any bar with the same timestamp
+func (c *series) merge(bar marketdata.Bar) (int, UpdateKind) {
+	i := sort.Search(len(c.bars), func(i int) bool {
+		return !c.bars[i].Timestamp.Before(bar.Timestamp)
+	})
+
+	if i < len(c.bars) && c.bars[i].Timestamp.Equal(bar.Timestamp) {
+		c.bars[i] = bar
+		return i, Replaced
+	}
+
+	c.bars = append(c.bars, marketdata.Bar{})
+	copy(c.bars[i+1:], c.bars[i:])
+	c.bars[i] = bar
+
+	return i, Appended
+}
+
+// Seed loads the symbol's intraday bars from the repository unless already seeded for the latest session. Bars
+// streamed before seeding completes take precedence over the historical ones.
+func (s *SeriesStore) Seed(symbol string) error {
+	_, err := s.seed(strings.ToUpper(symbol))
+
+	return err
+}
+
+// seed seeds the symbol's series if required and returns a copy of its bars, read under the same lock as the seeding
+// so that the series cannot be evicted in between
+func (s *SeriesStore) seed(symbol string) ([]marketdata.Bar, error) {
+	session, err := s.repository.LatestSession()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mut.RLock()
+	current, ok := s.series[symbol]
+
+	if ok && current.seeded && current.session == session {
+		defer s.mut.RUnlock()
+		return append([]marketdata.Bar(nil), current.bars...), nil
+	}
+
+	s.mut.RUnlock()
+
+	bars, err := s.repository.Intraday(symbol)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	current, ok = s.series[symbol]
+
+	if !ok || current.date < session {
+		current = &series{date: session}
+		s.series[symbol] = current
+	}
+
+	streamed := current.bars
+	current.bars = append([]marketdata.Bar(nil), bars...)
+
+	for _, bar := range streamed {
+		current.merge(bar)
+	}
+
+	current.seeded = true
+	current.session = session
+
+	return append([]marketdata.Bar(nil), current.bars...), nil
+}
+
+// Retain evicts the series of every symbol not in symbols
+func (s *SeriesStore) Retain(symbols ...string) {
+	retained := make(map[string]bool, len(symbols))
+
+	for _, symbol := range symbols {
+		retained[strings.ToUpper(symbol)] = true
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for symbol := range s.series {
+		if !retained[symbol] {
+			delete(s.series, symbol)
+		}
+	}
+}
+
+// Get returns a copy of the symbol's intraday bars, seeding the series first if required
+func (s *SeriesStore) Get(symbol string) ([]marketdata.Bar, error) {
+	return s.seed(strings.ToUpper(symbol))
+}
+
+// DailyBar returns the latest streamed daily bar for the symbol, if any
+func (s *SeriesStore) DailyBar(symbol string) *marketdata.Bar {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	if current, ok := s.series[strings.ToUpper(symbol)]; ok && current.dailyBar != nil {
+		dailyBar := *current.dailyBar
+		return &dailyBar
+	}
+
+	return nil
+}
+
+// ApplyBar merges a minute bar or an updated minute bar into the symbol's series
+func (s *SeriesStore) ApplyBar(streamBar stream.Bar) Update {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	bar := ToBar(streamBar)
+	index, kind := s.get(streamBar.Symbol, streamBar.Timestamp).merge(bar)
+
+	return Update{
+		Symbol: streamBar.Symbol,
+		Kind:   kind,
+		Index:  index,
+		Bar:    bar,
+	}
+}
+
+// ApplyDailyBar records the symbol's running daily bar
+func (s *SeriesStore) ApplyDailyBar(streamBar stream.Bar) Update {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	bar := ToBar(streamBar)
+	current, ok := s.series[streamBar.Symbol]
+
+	if !ok {
+		current = &series{date: sessionDate(streamBar.Timestamp)}
+		s.series[streamBar.Symbol] = current
+	}
+
+	current.dailyBar = &bar
+
+	return Update{
+		Symbol: streamBar.Symbol,
+		Kind:   Daily,
+		Bar:    bar,
+	}
+}
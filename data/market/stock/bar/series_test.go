@@ -0,0 +1,52 @@
+package bar
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"testing"
+	"time"
+)
+
+func TestSeriesStoreReseedsAfterSessionChange(t *testing.T) {
+	store := NewSeriesStore(newFakeRepository(t, fake.NewMarket(fake.Options{})))
+
+	expected, err := store.Get("AAPL")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a series seeded for an earlier session
+	stale := marketdata.Bar{Timestamp: time.Date(2000, 1, 3, 14, 30, 0, 0, time.UTC), Close: 1}
+	store.series["AAPL"] = &series{date: "2000-01-03", seeded: true, session: "2000-01-03", bars: []marketdata.Bar{stale}}
+
+	bars, err := store.Get("AAPL")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bars) != len(expected) || (len(bars) > 0 && bars[0].Timestamp.Equal(stale.Timestamp)) {
+		t.Fatalf("expected %d bars from the latest session, got %d", len(expected), len(bars))
+	}
+}
+
+func TestSeriesStoreRetainEvictsUnstreamedSymbols(t *testing.T) {
+	store := NewSeriesStore(newFakeRepository(t, fake.NewMarket(fake.Options{})))
+
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		if err := store.Seed(symbol); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.Retain("msft")
+
+	if _, ok := store.series["AAPL"]; ok {
+		t.Fatal("expected AAPL to be evicted")
+	}
+
+	if _, ok := store.series["MSFT"]; !ok {
+		t.Fatal("expected MSFT to be retained")
+	}
+}
@@ -0,0 +1,134 @@
+package bar
+
+import (
+	"errors"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"sort"
+	"time"
+)
+
+// MaxSessions is the most trading days returned by Sessions
+const MaxSessions = 30
+
+var ErrInvalidSessionTimeFrame = errors.New("sessions require a minute or hour timeframe")
+
+// SegmentKind is the part of the extended trading day a segment covers
+type SegmentKind string
+
+const (
+	PreMarket  SegmentKind = "pre"
+	Regular    SegmentKind = "regular"
+	PostMarket SegmentKind = "post"
+)
+
+// Segment is the pre-market, regular or post-market part of a trading day. From and To index the session's bars,
+// [From, To).
+type Segment struct {
+	Kind  SegmentKind `json:"kind"`
+	Start time.Time   `json:"start"`
+	End   time.Time   `json:"end"`
+	From  int         `json:"from"`
+	To    int         `json:"to"`
+}
+
+// Session is a trading day's bars. Offset is the position of its first bar in the series stitched from every
+// session, so that sessions can be charted end to end without the overnight gaps.
+type Session struct {
+	Date         string           `json:"date"`
+	SessionOpen  time.Time        `json:"sessionOpen"`
+	Open         time.Time        `json:"open"`
+	Close        time.Time        `json:"close"`
+	SessionClose time.Time        `json:"sessionClose"`
+	Offset       int              `json:"offset"`
+	Bars         []marketdata.Bar `json:"bars"`
+	Segments     []Segment        `json:"segments"`
+}
+
+// Sessions are a symbol's intraday bars over consecutive trading days, oldest first
+type Sessions struct {
+	Symbol    string    `json:"symbol"`
+	TimeFrame string    `json:"timeFrame"`
+	Sessions  []Session `json:"sessions"`
+}
+
+func newSession(day calendar.Calendar) Session {
+	return Session{
+		Date:         day.Date,
+		SessionOpen:  day.SessionOpen,
+		Open:         day.Open,
+		Close:        day.Close,
+		SessionClose: day.SessionClose,
+		Bars:         []marketdata.Bar{},
+	}
+}
+
+// segment annotates the bars between start and end
+func (s *Session) segment(kind SegmentKind, start, end time.Time) {
+	s.Segments = append(s.Segments, Segment{
+		Kind:  kind,
+		Start: start,
+		End:   end,
+		From:  sort.Search(len(s.Bars), func(i int) bool { return !s.Bars[i].Timestamp.Before(start) }),
+		To:    sort.Search(len(s.Bars), func(i int) bool { return !s.Bars[i].Timestamp.Before(end) }),
+	})
+}
+
+// Sessions returns the symbol's minute or hour bars for the latest trading days, grouped by day and split into
+// pre-market, regular and post-market segments using the calendar's open and close, which include early closes
+func (b *Repository) Sessions(symbol string, days int, timeFrame marketdata.TimeFrame) (*Sessions, error) {
+	if timeFrame.Unit != marketdata.Min && timeFrame.Unit != marketdata.Hour {
+		return nil, ErrInvalidSessionTimeFrame
+	}
+
+	if days < 1 {
+		days = 1
+	}
+
+	if days > MaxSessions {
+		days = MaxSessions
+	}
+
+	tradingDays, err := b.latestSessions(days)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bars, err := b.Bars(symbol, timeFrame, tradingDays[0].SessionOpen, tradingDays[len(tradingDays)-1].SessionClose, marketdata.Split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Sessions{
+		Symbol:    symbol,
+		TimeFrame: timeFrame.String(),
+		Sessions:  make([]Session, len(tradingDays)),
+	}
+
+	i := 0
+	offset := 0
+
+	for d, day := range tradingDays {
+		session := newSession(day)
+
+		// bars outside the extended session, such as overnight bars, are dropped
+		for ; i < len(bars) && bars[i].Timestamp.Before(day.SessionClose); i++ {
+			if !bars[i].Timestamp.Before(day.SessionOpen) {
+				session.Bars = append(session.Bars, bars[i])
+			}
+		}
+
+		session.segment(PreMarket, day.SessionOpen, day.Open)
+		session.segment(Regular, day.Open, day.Close)
+		session.segment(PostMarket, day.Close, day.SessionClose)
+
+		session.Offset = offset
+		offset += len(session.Bars)
+
+		result.Sessions[d] = session
+	}
+
+	return result, nil
+}
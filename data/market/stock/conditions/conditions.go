@@ -0,0 +1,231 @@
+package conditions
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Condition describes a trade condition code and how it affects the consolidated last sale, high/low and volume
+type Condition struct {
+	Code           string `json:"code"`
+	Description    string `json:"description"`
+	UpdatesLast    bool   `json:"updatesLast"`
+	UpdatesHighLow bool   `json:"updatesHighLow"`
+	UpdatesVolume  bool   `json:"updatesVolume"`
+}
+
+// Plan identifies the securities information processor that assigned a trade's conditions
+type Plan string
+
+const (
+	// CTA covers NYSE (tape A) and NYSE Arca, NYSE American and regional (tape B) listed securities
+	CTA Plan = "CTA"
+	// UTP covers Nasdaq (tape C) listed securities
+	UTP Plan = "UTP"
+)
+
+func condition(code, description string, updatesHighLow, updatesLast, updatesVolume bool) Condition {
+	return Condition{
+		Code:           code,
+		Description:    description,
+		UpdatesLast:    updatesLast,
+		UpdatesHighLow: updatesHighLow,
+		UpdatesVolume:  updatesVolume,
+	}
+}
+
+// ctaConditions follows the CTA plan's sale condition eligibility table (high/low, last, volume)
+var ctaConditions = index(
+	condition(" ", "Regular Sale", true, true, true),
+	condition("@", "Regular Sale", true, true, true),
+	condition("B", "Average Price Trade", false, false, true),
+	condition("C", "Cash Trade (Same Day Clearing)", false, false, true),
+	condition("E", "Automatic Execution", true, true, true),
+	condition("F", "Intermarket Sweep Order", true, true, true),
+	condition("H", "Price Variation Trade", false, false, true),
+	condition("I", "Odd Lot Trade", false, false, true),
+	condition("K", "Rule 127 (NYSE) or Rule 155 (NYSE American)", true, true, true),
+	condition("L", "Sold Last", true, true, true),
+	condition("M", "Market Center Official Close", false, false, false),
+	condition("N", "Next Day Trade", false, false, true),
+	condition("O", "Market Center Opening Trade", true, true, true),
+	condition("P", "Prior Reference Price", true, false, true),
+	condition("Q", "Market Center Official Open", false, false, false),
+	condition("R", "Seller", false, false, true),
+	condition("T", "Extended Hours Trade", false, false, true),
+	condition("U", "Extended Hours (Sold Out of Sequence)", false, false, true),
+	condition("V", "Contingent Trade", false, false, true),
+	condition("X", "Cross Trade", true, true, true),
+	condition("Z", "Sold (Out of Sequence)", true, false, true),
+	condition("4", "Derivatively Priced", true, false, true),
+	condition("5", "Market Center Reopening Trade", true, true, true),
+	condition("6", "Market Center Closing Trade", true, true, true),
+	condition("7", "Qualified Contingent Trade", false, false, true),
+	condition("8", "Placeholder for 611 Exempt", true, true, true),
+	condition("9", "Corrected Consolidated Close", true, true, false),
+)
+
+// utpConditions follows the UTP plan's sale condition eligibility table (high/low, last, volume)
+var utpConditions = index(
+	condition(" ", "Regular Sale", true, true, true),
+	condition("@", "Regular Sale", true, true, true),
+	condition("A", "Acquisition", true, true, true),
+	condition("B", "Bunched Trade", true, true, true),
+	condition("C", "Cash Sale", false, false, true),
+	condition("D", "Distribution", true, true, true),
+	condition("E", "Placeholder", true, true, true),
+	condition("F", "Intermarket Sweep", true, true, true),
+	condition("G", "Bunched Sold Trade", true, false, true),
+	condition("H", "Price Variation Trade", false, false, true),
+	condition("I", "Odd Lot Trade", false, false, true),
+	condition("K", "Rule 155 Trade (AMEX)", true, true, true),
+	condition("L", "Sold Last", true, true, true),
+	condition("M", "Market Center Official Close", false, false, false),
+	condition("N", "Next Day", false, false, true),
+	condition("O", "Opening Prints", true, true, true),
+	condition("P", "Prior Reference Price", true, false, true),
+	condition("Q", "Market Center Official Open", false, false, false),
+	condition("R", "Seller", false, false, true),
+	condition("S", "Split Trade", true, true, true),
+	condition("T", "Form T", false, false, true),
+	condition("U", "Extended Trading Hours (Sold Out of Sequence)", false, false, true),
+	condition("V", "Contingent Trade", false, false, true),
+	condition("W", "Average Price Trade", false, false, true),
+	condition("X", "Cross Trade", true, true, true),
+	condition("Y", "Yellow Flag Regular Trade", true, true, true),
+	condition("Z", "Sold (Out of Sequence)", true, false, true),
+	condition("1", "Stopped Stock (Regular Trade)", true, true, true),
+	condition("4", "Derivatively Priced", true, false, true),
+	condition("5", "Re-Opening Prints", true, true, true),
+	condition("6", "Closing Prints", true, true, true),
+	condition("7", "Qualified Contingent Trade", false, false, true),
+	condition("8", "Placeholder for 611 Exempt", true, true, true),
+	condition("9", "Corrected Consolidated Close (Per Listing Market)", true, true, false),
+)
+
+func index(conditions ...Condition) map[string]Condition {
+	indexed := make(map[string]Condition, len(conditions))
+
+	for _, c := range conditions {
+		indexed[c.Code] = c
+	}
+
+	return indexed
+}
+
+// PlanForTape returns the plan whose condition codes apply to trades on the tape
+func PlanForTape(tape string) Plan {
+	if strings.ToUpper(tape) == "C" {
+		return UTP
+	}
+
+	return CTA
+}
+
+func table(plan Plan) map[string]Condition {
+	if plan == UTP {
+		return utpConditions
+	}
+
+	return ctaConditions
+}
+
+// Lookup returns the condition for the code on the tape. Unknown codes are treated as regular sales.
+func Lookup(tape, code string) (Condition, bool) {
+	c, ok := table(PlanForTape(tape))[code]
+
+	if !ok {
+		return condition(code, "Unknown", true, true, true), false
+	}
+
+	return c, true
+}
+
+// All returns the conditions defined by the plan
+func All(plan Plan) []Condition {
+	conditions := make([]Condition, 0, len(table(plan)))
+
+	for _, c := range table(plan) {
+		conditions = append(conditions, c)
+	}
+
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].Code < conditions[j].Code
+	})
+
+	return conditions
+}
+
+// Eligibility is whether a trade, given all of its conditions, updates the last sale, high/low and volume
+type Eligibility struct {
+	UpdatesLast    bool `json:"updatesLast"`
+	UpdatesHighLow bool `json:"updatesHighLow"`
+	UpdatesVolume  bool `json:"updatesVolume"`
+}
+
+// Evaluate combines the trade's conditions; a trade only updates a value if every one of its conditions allows it
+func Evaluate(tape string, codes []string) Eligibility {
+	eligibility := Eligibility{
+		UpdatesLast:    true,
+		UpdatesHighLow: true,
+		UpdatesVolume:  true,
+	}
+
+	for _, code := range codes {
+		c, _ := Lookup(tape, code)
+
+		eligibility.UpdatesLast = eligibility.UpdatesLast && c.UpdatesLast
+		eligibility.UpdatesHighLow = eligibility.UpdatesHighLow && c.UpdatesHighLow
+		eligibility.UpdatesVolume = eligibility.UpdatesVolume && c.UpdatesVolume
+	}
+
+	return eligibility
+}
+
+// Describe returns the descriptions of the trade's conditions
+func Describe(tape string, codes []string) []string {
+	descriptions := make([]string, len(codes))
+
+	for i, code := range codes {
+		c, _ := Lookup(tape, code)
+		descriptions[i] = c.Description
+	}
+
+	return descriptions
+}
+
+// LastSale calculates the consolidated last sale, high, low and volume from trades according to their conditions
+type LastSale struct {
+	Price     float64   `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Volume    uint64    `json:"volume"`
+}
+
+// Apply updates the last sale with a trade, returning the trade's eligibility
+func (l *LastSale) Apply(tape string, codes []string, price float64, size uint32, timestamp time.Time) Eligibility {
+	eligibility := Evaluate(tape, codes)
+
+	if eligibility.UpdatesVolume {
+		l.Volume += uint64(size)
+	}
+
+	if eligibility.UpdatesHighLow {
+		if l.High == 0 || price > l.High {
+			l.High = price
+		}
+
+		if l.Low == 0 || price < l.Low {
+			l.Low = price
+		}
+	}
+
+	if eligibility.UpdatesLast && !timestamp.Before(l.Timestamp) {
+		l.Price = price
+		l.Timestamp = timestamp
+	}
+
+	return eligibility
+}
@@ -0,0 +1,121 @@
+package conditions
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanForTape(t *testing.T) {
+	tests := []struct {
+		tape     string
+		expected Plan
+	}{
+		{"A", CTA},
+		{"B", CTA},
+		{"C", UTP},
+		{"c", UTP},
+		{"", CTA},
+	}
+
+	for _, test := range tests {
+		if actual := PlanForTape(test.tape); actual != test.expected {
+			t.Errorf("tape %q: expected %s, got %s", test.tape, test.expected, actual)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		tape       string
+		code       string
+		expected   Condition
+		expectedOk bool
+	}{
+		{"CTA average price", "A", "B", condition("B", "Average Price Trade", false, false, true), true},
+		{"UTP bunched trade", "C", "B", condition("B", "Bunched Trade", true, true, true), true},
+		{"UTP average price", "C", "W", condition("W", "Average Price Trade", false, false, true), true},
+		{"UTP bunched sold", "C", "G", condition("G", "Bunched Sold Trade", true, false, true), true},
+		{"CTA has no bunched sold", "B", "G", condition("G", "Unknown", true, true, true), false},
+		{"CTA extended hours", "A", "T", condition("T", "Extended Hours Trade", false, false, true), true},
+		{"UTP form T", "C", "T", condition("T", "Form T", false, false, true), true},
+		{"official close", "C", "M", condition("M", "Market Center Official Close", false, false, false), true},
+		{"unknown", "A", "?", condition("?", "Unknown", true, true, true), false},
+	}
+
+	for _, test := range tests {
+		actual, ok := Lookup(test.tape, test.code)
+
+		if actual != test.expected || ok != test.expectedOk {
+			t.Errorf("%s: expected %+v (%v), got %+v (%v)", test.name, test.expected, test.expectedOk, actual, ok)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		tape     string
+		codes    []string
+		expected Eligibility
+	}{
+		{"no conditions", "A", nil, Eligibility{true, true, true}},
+		{"regular sale", "C", []string{"@"}, Eligibility{true, true, true}},
+		{"odd lot", "A", []string{"@", "I"}, Eligibility{false, false, true}},
+		{"out of sequence", "C", []string{"Z"}, Eligibility{false, true, true}},
+		{"extended hours out of sequence", "A", []string{"T", "U"}, Eligibility{false, false, true}},
+		{"derivatively priced", "A", []string{"4"}, Eligibility{false, true, true}},
+		{"intermarket sweep odd lot", "C", []string{"F", "I"}, Eligibility{false, false, true}},
+		{"official close", "A", []string{"M"}, Eligibility{false, false, false}},
+		{"corrected close", "C", []string{"9"}, Eligibility{true, true, false}},
+		{"CTA average price", "A", []string{"B"}, Eligibility{false, false, true}},
+		{"UTP bunched trade", "C", []string{"B"}, Eligibility{true, true, true}},
+	}
+
+	for _, test := range tests {
+		if actual := Evaluate(test.tape, test.codes); actual != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestLastSaleApply(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	at := func(minute int) time.Time {
+		return time.Date(2026, 10, 16, 10, minute, 0, 0, newYork)
+	}
+
+	trades := []struct {
+		name      string
+		tape      string
+		codes     []string
+		price     float64
+		size      uint32
+		timestamp time.Time
+		expected  LastSale
+	}{
+		{"regular", "C", []string{"@"}, 100, 100, at(1), LastSale{Price: 100, Timestamp: at(1), High: 100, Low: 100, Volume: 100}},
+		{"odd lot", "C", []string{"@", "I"}, 120, 10, at(2), LastSale{Price: 100, Timestamp: at(1), High: 100, Low: 100, Volume: 110}},
+		{"higher", "C", []string{"@"}, 101, 100, at(3), LastSale{Price: 101, Timestamp: at(3), High: 101, Low: 100, Volume: 210}},
+		{"out of sequence", "C", []string{"Z"}, 99, 100, at(0), LastSale{Price: 101, Timestamp: at(3), High: 101, Low: 99, Volume: 310}},
+		{"derivatively priced", "A", []string{"4"}, 102, 100, at(4), LastSale{Price: 101, Timestamp: at(3), High: 102, Low: 99, Volume: 410}},
+		{"late regular", "C", []string{"@"}, 98, 100, at(2), LastSale{Price: 101, Timestamp: at(3), High: 102, Low: 98, Volume: 510}},
+		{"official close", "C", []string{"M"}, 150, 1000, at(5), LastSale{Price: 101, Timestamp: at(3), High: 102, Low: 98, Volume: 510}},
+		{"UTP bunched trade", "C", []string{"B"}, 100.5, 100, at(5), LastSale{Price: 100.5, Timestamp: at(5), High: 102, Low: 98, Volume: 610}},
+		{"CTA average price", "A", []string{"B"}, 90, 100, at(6), LastSale{Price: 100.5, Timestamp: at(5), High: 102, Low: 98, Volume: 710}},
+	}
+
+	var lastSale LastSale
+
+	for _, trade := range trades {
+		eligibility := lastSale.Apply(trade.tape, trade.codes, trade.price, trade.size, trade.timestamp)
+
+		if expected := Evaluate(trade.tape, trade.codes); eligibility != expected {
+			t.Fatalf("%s: expected eligibility %+v, got %+v", trade.name, expected, eligibility)
+		}
+
+		if lastSale != trade.expected {
+			t.Fatalf("%s: expected %+v, got %+v", trade.name, trade.expected, lastSale)
+		}
+	}
+}
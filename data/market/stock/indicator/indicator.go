@@ -0,0 +1,466 @@
+package indicator
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"math"
+	"time"
+)
+
+// Point is an indicator's values at the time of a bar. Values are omitted until the indicator has enough bars.
+type Point struct {
+	Time   time.Time          `json:"t"`
+	Values map[string]float64 `json:"v,omitempty"`
+}
+
+// Series is an indicator's points, one for each bar
+type Series struct {
+	Spec    string   `json:"spec"`
+	Outputs []string `json:"outputs"`
+	Points  []Point  `json:"points"`
+}
+
+// calculator computes an indicator one bar at a time
+type calculator interface {
+	add(bar marketdata.Bar) map[string]float64
+	// clone copies the calculator's state, so that a bar can be replaced by adding its update to the copy made
+	// before it
+	clone() calculator
+}
+
+func newCalculator(spec Spec) calculator {
+	switch spec.Kind {
+	case SMA:
+		return &sma{values: newWindow(spec.Periods[0])}
+	case EMA:
+		return &emaCalculator{average: newEMA(spec.Periods[0])}
+	case WMA:
+		return &wma{values: newWindow(spec.Periods[0])}
+	case RSI:
+		return &rsi{gains: newWilder(spec.Periods[0]), losses: newWilder(spec.Periods[0])}
+	case MACD:
+		return &macd{fast: newEMA(spec.Periods[0]), slow: newEMA(spec.Periods[1]), signal: newEMA(spec.Periods[2])}
+	case Bollinger:
+		return &bollinger{values: newWindow(spec.Periods[0]), multiplier: spec.Multiplier}
+	case ATR:
+		return &atr{average: newWilder(spec.Periods[0])}
+	case Stochastic:
+		return &stochastic{highs: newWindow(spec.Periods[0]), lows: newWindow(spec.Periods[0]), k: newWindow(spec.Periods[1])}
+	case OBV:
+		return &obv{}
+	default:
+		return &anchoredVWAP{anchor: spec.Anchor}
+	}
+}
+
+// Compute returns the indicator's points for bars sorted by timestamp
+func Compute(bars []marketdata.Bar, spec Spec) Series {
+	c := newCalculator(spec)
+
+	series := Series{
+		Spec:    spec.String(),
+		Outputs: spec.Outputs(),
+		Points:  make([]Point, len(bars)),
+	}
+
+	for i, bar := range bars {
+		series.Points[i] = Point{Time: bar.Timestamp, Values: c.add(bar)}
+	}
+
+	return series
+}
+
+// window holds the latest values up to its size
+type window struct {
+	size   int
+	values []float64
+}
+
+func newWindow(size int) window {
+	return window{size: size, values: make([]float64, 0, size)}
+}
+
+func (w *window) add(value float64) {
+	w.values = append(w.values, value)
+
+	if len(w.values) > w.size {
+		w.values = w.values[1:]
+	}
+}
+
+func (w window) full() bool {
+	return len(w.values) == w.size
+}
+
+func (w window) copy() window {
+	values := make([]float64, len(w.values), w.size)
+	copy(values, w.values)
+
+	return window{size: w.size, values: values}
+}
+
+func (w window) mean() float64 {
+	sum := 0.0
+
+	for _, value := range w.values {
+		sum += value
+	}
+
+	return sum / float64(len(w.values))
+}
+
+func (w window) max() float64 {
+	result := math.Inf(-1)
+
+	for _, value := range w.values {
+		result = math.Max(result, value)
+	}
+
+	return result
+}
+
+func (w window) min() float64 {
+	result := math.Inf(1)
+
+	for _, value := range w.values {
+		result = math.Min(result, value)
+	}
+
+	return result
+}
+
+// ema is an exponential moving average seeded with the simple average of its first period
+type ema struct {
+	period int
+	alpha  float64
+	count  int
+	sum    float64
+	value  float64
+}
+
+func newEMA(period int) ema {
+	return ema{period: period, alpha: 2 / (float64(period) + 1)}
+}
+
+// newWilder returns Wilder's smoothed average, used by RSI and ATR
+func newWilder(period int) ema {
+	return ema{period: period, alpha: 1 / float64(period)}
+}
+
+func (e *ema) add(value float64) (float64, bool) {
+	e.count++
+
+	if e.count > e.period {
+		e.value += e.alpha * (value - e.value)
+
+		return e.value, true
+	}
+
+	e.sum += value
+
+	if e.count < e.period {
+		return 0, false
+	}
+
+	e.value = e.sum / float64(e.period)
+
+	return e.value, true
+}
+
+type sma struct {
+	values window
+}
+
+func (s *sma) add(bar marketdata.Bar) map[string]float64 {
+	s.values.add(bar.Close)
+
+	if !s.values.full() {
+		return nil
+	}
+
+	return map[string]float64{"value": s.values.mean()}
+}
+
+func (s *sma) clone() calculator {
+	return &sma{values: s.values.copy()}
+}
+
+type emaCalculator struct {
+	average ema
+}
+
+func (e *emaCalculator) add(bar marketdata.Bar) map[string]float64 {
+	value, ok := e.average.add(bar.Close)
+
+	if !ok {
+		return nil
+	}
+
+	return map[string]float64{"value": value}
+}
+
+func (e *emaCalculator) clone() calculator {
+	c := *e
+
+	return &c
+}
+
+// wma weights the latest close by the period, the one before by one less, and so on
+type wma struct {
+	values window
+}
+
+func (w *wma) add(bar marketdata.Bar) map[string]float64 {
+	w.values.add(bar.Close)
+
+	if !w.values.full() {
+		return nil
+	}
+
+	sum, weights := 0.0, 0.0
+
+	for i, value := range w.values.values {
+		sum += float64(i+1) * value
+		weights += float64(i + 1)
+	}
+
+	return map[string]float64{"value": sum / weights}
+}
+
+func (w *wma) clone() calculator {
+	return &wma{values: w.values.copy()}
+}
+
+type rsi struct {
+	gains    ema
+	losses   ema
+	previous float64
+	started  bool
+}
+
+func (r *rsi) add(bar marketdata.Bar) map[string]float64 {
+	if !r.started {
+		r.previous = bar.Close
+		r.started = true
+
+		return nil
+	}
+
+	change := bar.Close - r.previous
+	r.previous = bar.Close
+
+	gain, ok := r.gains.add(math.Max(change, 0))
+	loss, _ := r.losses.add(math.Max(-change, 0))
+
+	if !ok {
+		return nil
+	}
+
+	value := 50.0
+
+	if loss == 0 && gain > 0 {
+		value = 100
+	} else if loss > 0 {
+		value = 100 - 100/(1+gain/loss)
+	}
+
+	return map[string]float64{"value": value}
+}
+
+func (r *rsi) clone() calculator {
+	c := *r
+
+	return &c
+}
+
+type macd struct {
+	fast   ema
+	slow   ema
+	signal ema
+}
+
+func (m *macd) add(bar marketdata.Bar) map[string]float64 {
+	fast, _ := m.fast.add(bar.Close)
+	slow, ok := m.slow.add(bar.Close)
+
+	if !ok {
+		return nil
+	}
+
+	value := fast - slow
+	values := map[string]float64{"macd": value}
+
+	if signal, ok := m.signal.add(value); ok {
+		values["signal"] = signal
+		values["histogram"] = value - signal
+	}
+
+	return values
+}
+
+func (m *macd) clone() calculator {
+	c := *m
+
+	return &c
+}
+
+// bollinger bands are the multiplier's population standard deviations either side of the simple moving average
+type bollinger struct {
+	values     window
+	multiplier float64
+}
+
+func (b *bollinger) add(bar marketdata.Bar) map[string]float64 {
+	b.values.add(bar.Close)
+
+	if !b.values.full() {
+		return nil
+	}
+
+	mean := b.values.mean()
+	variance := 0.0
+
+	for _, value := range b.values.values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	deviation := math.Sqrt(variance / float64(len(b.values.values)))
+
+	return map[string]float64{
+		"upper":  mean + b.multiplier*deviation,
+		"middle": mean,
+		"lower":  mean - b.multiplier*deviation,
+	}
+}
+
+func (b *bollinger) clone() calculator {
+	return &bollinger{values: b.values.copy(), multiplier: b.multiplier}
+}
+
+type atr struct {
+	average  ema
+	previous float64
+	started  bool
+}
+
+func (a *atr) add(bar marketdata.Bar) map[string]float64 {
+	trueRange := bar.High - bar.Low
+
+	if a.started {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(bar.High-a.previous), math.Abs(bar.Low-a.previous)))
+	}
+
+	a.previous = bar.Close
+	a.started = true
+
+	value, ok := a.average.add(trueRange)
+
+	if !ok {
+		return nil
+	}
+
+	return map[string]float64{"value": value}
+}
+
+func (a *atr) clone() calculator {
+	c := *a
+
+	return &c
+}
+
+// stochastic is the close's position within the period's range as %K, and the average of %K as %D
+type stochastic struct {
+	highs window
+	lows  window
+	k     window
+}
+
+func (s *stochastic) add(bar marketdata.Bar) map[string]float64 {
+	s.highs.add(bar.High)
+	s.lows.add(bar.Low)
+
+	if !s.highs.full() {
+		return nil
+	}
+
+	highest, lowest := s.highs.max(), s.lows.min()
+	k := 50.0
+
+	if highest > lowest {
+		k = 100 * (bar.Close - lowest) / (highest - lowest)
+	}
+
+	s.k.add(k)
+	values := map[string]float64{"k": k}
+
+	if s.k.full() {
+		values["d"] = s.k.mean()
+	}
+
+	return values
+}
+
+func (s *stochastic) clone() calculator {
+	return &stochastic{highs: s.highs.copy(), lows: s.lows.copy(), k: s.k.copy()}
+}
+
+// obv adds the volume of bars that close higher and subtracts the volume of bars that close lower
+type obv struct {
+	value    float64
+	previous float64
+	started  bool
+}
+
+func (o *obv) add(bar marketdata.Bar) map[string]float64 {
+	if o.started {
+		if bar.Close > o.previous {
+			o.value += float64(bar.Volume)
+		} else if bar.Close < o.previous {
+			o.value -= float64(bar.Volume)
+		}
+	}
+
+	o.previous = bar.Close
+	o.started = true
+
+	return map[string]float64{"value": o.value}
+}
+
+func (o *obv) clone() calculator {
+	c := *o
+
+	return &c
+}
+
+// anchoredVWAP weights each bar's VWAP, or its typical price if it has none, by its volume
+type anchoredVWAP struct {
+	anchor   time.Time
+	notional float64
+	volume   float64
+}
+
+func (a *anchoredVWAP) add(bar marketdata.Bar) map[string]float64 {
+	if bar.Timestamp.Before(a.anchor) {
+		return nil
+	}
+
+	price := bar.VWAP
+
+	if price == 0 {
+		price = (bar.High + bar.Low + bar.Close) / 3
+	}
+
+	a.notional += price * float64(bar.Volume)
+	a.volume += float64(bar.Volume)
+
+	if a.volume == 0 {
+		return nil
+	}
+
+	return map[string]float64{"value": a.notional / a.volume}
+}
+
+func (a *anchoredVWAP) clone() calculator {
+	c := *a
+
+	return &c
+}
@@ -0,0 +1,32 @@
+package indicator
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"testing"
+	"time"
+)
+
+func TestComputeOverBars(t *testing.T) {
+	spec, err := ParseSpec("sma:3")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC)
+	bars := make([]marketdata.Bar, 5)
+
+	for i := range bars {
+		bars[i] = marketdata.Bar{Timestamp: start.Add(time.Duration(i) * time.Hour), Close: float64(i + 1)}
+	}
+
+	series := Compute(bars, spec)
+
+	if len(series.Points) != len(bars) || series.Points[1].Values != nil {
+		t.Fatalf("expected no value before 3 bars, got %+v", series.Points)
+	}
+
+	if value := series.Points[4].Values["value"]; value != 4 || !series.Points[4].Time.Equal(bars[4].Timestamp) {
+		t.Fatalf("expected the mean of 3, 4 and 5, got %v", value)
+	}
+}
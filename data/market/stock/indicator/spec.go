@@ -0,0 +1,184 @@
+package indicator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the indicator computed
+type Kind string
+
+const (
+	SMA        Kind = "sma"
+	EMA        Kind = "ema"
+	WMA        Kind = "wma"
+	RSI        Kind = "rsi"
+	MACD       Kind = "macd"
+	Bollinger  Kind = "bb"
+	ATR        Kind = "atr"
+	Stochastic Kind = "stoch"
+	OBV        Kind = "obv"
+	// AnchoredVWAP is the volume weighted average price of the bars since its anchor
+	AnchoredVWAP Kind = "avwap"
+)
+
+var ErrInvalidSpec = errors.New("invalid indicator spec")
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// defaults are the periods used when a spec omits them, and the number of periods each kind accepts
+var defaults = map[Kind][]int{
+	SMA:          {20},
+	EMA:          {20},
+	WMA:          {20},
+	RSI:          {14},
+	MACD:         {12, 26, 9},
+	Bollinger:    {20},
+	ATR:          {14},
+	Stochastic:   {14, 3},
+	OBV:          {},
+	AnchoredVWAP: {},
+}
+
+// Spec describes an indicator and its parameters
+type Spec struct {
+	Kind    Kind
+	Periods []int
+	// Multiplier is the number of standard deviations between the Bollinger bands and their middle
+	Multiplier float64
+	// Anchor is the time from which an anchored VWAP starts, the zero time starts it from the first bar
+	Anchor time.Time
+}
+
+// ParseSpec parses a spec written as kind:parameters, e.g. "sma:50", "ema:9", "rsi:14", "macd:12,26,9", "bb:20,2",
+// "atr:14", "stoch:14,3", "obv" or "avwap:2022-06-01T09:30:00-04:00". Omitted parameters take common defaults, and
+// an anchored VWAP anchored to a date starts at midnight in New York.
+func ParseSpec(s string) (Spec, error) {
+	s = strings.TrimSpace(s)
+
+	kind, parameters, _ := strings.Cut(s, ":")
+
+	spec := Spec{Kind: Kind(strings.ToLower(strings.TrimSpace(kind)))}
+
+	periods, ok := defaults[spec.Kind]
+
+	if !ok {
+		return Spec{}, fmt.Errorf("%w %q: unknown kind %q", ErrInvalidSpec, s, kind)
+	}
+
+	parameters = strings.TrimSpace(parameters)
+
+	if spec.Kind == AnchoredVWAP {
+		if parameters == "" {
+			return spec, nil
+		}
+
+		anchor, err := time.Parse(time.RFC3339, parameters)
+
+		if err != nil {
+			anchor, err = time.ParseInLocation("2006-01-02", parameters, newYork)
+		}
+
+		if err != nil {
+			return Spec{}, fmt.Errorf("%w %q: the anchor must be a date or RFC 3339 time", ErrInvalidSpec, s)
+		}
+
+		spec.Anchor = anchor
+
+		return spec, nil
+	}
+
+	spec.Periods = append([]int{}, periods...)
+
+	if spec.Kind == Bollinger {
+		spec.Multiplier = 2
+	}
+
+	if parameters == "" {
+		return spec, nil
+	}
+
+	values := strings.Split(parameters, ",")
+
+	limit := len(periods)
+
+	if spec.Kind == Bollinger {
+		limit++
+	}
+
+	if len(values) > limit {
+		return Spec{}, fmt.Errorf("%w %q: %s takes at most %d parameters", ErrInvalidSpec, s, spec.Kind, limit)
+	}
+
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+
+		if spec.Kind == Bollinger && i == 1 {
+			multiplier, err := strconv.ParseFloat(value, 64)
+
+			if err != nil || multiplier <= 0 {
+				return Spec{}, fmt.Errorf("%w %q: the multiplier must be positive", ErrInvalidSpec, s)
+			}
+
+			spec.Multiplier = multiplier
+
+			continue
+		}
+
+		period, err := strconv.Atoi(value)
+
+		if err != nil || period < 1 {
+			return Spec{}, fmt.Errorf("%w %q: periods must be positive whole numbers", ErrInvalidSpec, s)
+		}
+
+		spec.Periods[i] = period
+	}
+
+	if spec.Kind == MACD && spec.Periods[0] >= spec.Periods[1] {
+		return Spec{}, fmt.Errorf("%w %q: the fast period must be shorter than the slow period", ErrInvalidSpec, s)
+	}
+
+	return spec, nil
+}
+
+func (s Spec) String() string {
+	switch s.Kind {
+	case OBV:
+		return string(s.Kind)
+	case AnchoredVWAP:
+		if s.Anchor.IsZero() {
+			return string(s.Kind)
+		}
+
+		return fmt.Sprintf("%s:%s", s.Kind, s.Anchor.Format(time.RFC3339))
+	}
+
+	parameters := make([]string, 0, len(s.Periods)+1)
+
+	for _, period := range s.Periods {
+		parameters = append(parameters, strconv.Itoa(period))
+	}
+
+	if s.Kind == Bollinger {
+		parameters = append(parameters, strconv.FormatFloat(s.Multiplier, 'f', -1, 64))
+	}
+
+	return fmt.Sprintf("%s:%s", s.Kind, strings.Join(parameters, ","))
+}
+
+// Outputs are the names of the values the indicator produces
+func (s Spec) Outputs() []string {
+	switch s.Kind {
+	case MACD:
+		return []string{"macd", "signal", "histogram"}
+	case Bollinger:
+		return []string{"upper", "middle", "lower"}
+	case Stochastic:
+		return []string{"k", "d"}
+	default:
+		return []string{"value"}
+	}
+}
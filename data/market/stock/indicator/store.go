@@ -0,0 +1,154 @@
+package indicator
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LateBars is the number of latest bars that a late or updated bar can replace, recomputing the points from it
+const LateBars = 30
+
+// Update is an indicator's point for a new or updated bar
+type Update struct {
+	Symbol string `json:"symbol"`
+	Spec   string `json:"spec"`
+	Point  Point  `json:"point"`
+}
+
+// Updates are the points changed by a bar
+type Updates []Update
+
+// tracker keeps an indicator current. base is the calculator's state before the latest bars, so that a late or
+// updated bar among them can be replaced by recomputing from it.
+type tracker struct {
+	spec    Spec
+	base    calculator
+	latest  []marketdata.Bar
+	current calculator
+}
+
+func newTracker(spec Spec) *tracker {
+	t := &tracker{
+		spec: spec,
+		base: newCalculator(spec),
+	}
+
+	t.current = t.base.clone()
+
+	return t
+}
+
+// add returns the point of a new bar, or the points from a replaced or inserted bar onwards. Bars older than the
+// latest bars are ignored.
+func (t *tracker) add(bar marketdata.Bar) []Point {
+	i := sort.Search(len(t.latest), func(i int) bool {
+		return !t.latest[i].Timestamp.Before(bar.Timestamp)
+	})
+
+	var points []Point
+
+	switch {
+	case i == len(t.latest):
+		t.latest = append(t.latest, bar)
+		points = []Point{{Time: bar.Timestamp, Values: t.current.add(bar)}}
+	case i == 0 && len(t.latest) == LateBars && bar.Timestamp.Before(t.latest[0].Timestamp):
+		return nil
+	default:
+		if t.latest[i].Timestamp.Equal(bar.Timestamp) {
+			t.latest[i] = bar
+		} else {
+			t.latest = append(t.latest, marketdata.Bar{})
+			copy(t.latest[i+1:], t.latest[i:])
+			t.latest[i] = bar
+		}
+
+		t.current = t.base.clone()
+
+		for j, b := range t.latest {
+			values := t.current.add(b)
+
+			if j >= i {
+				points = append(points, Point{Time: b.Timestamp, Values: values})
+			}
+		}
+	}
+
+	for len(t.latest) > LateBars {
+		t.base.add(t.latest[0])
+		t.latest = t.latest[1:]
+	}
+
+	return points
+}
+
+// Store keeps the indicators of each symbol current as bars are streamed
+type Store struct {
+	mut      sync.Mutex
+	trackers map[string][]*tracker
+}
+
+func NewStore() *Store {
+	return &Store{
+		trackers: make(map[string][]*tracker),
+	}
+}
+
+// Set replaces the symbol's indicators with the specs, computed over its bars, and returns their series
+func (s *Store) Set(symbol string, specs []Spec, bars []marketdata.Bar) []Series {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	trackers := make([]*tracker, len(specs))
+	result := make([]Series, len(specs))
+
+	for i, spec := range specs {
+		t := newTracker(spec)
+
+		result[i] = Series{
+			Spec:    spec.String(),
+			Outputs: spec.Outputs(),
+			Points:  make([]Point, 0, len(bars)),
+		}
+
+		for _, bar := range bars {
+			result[i].Points = append(result[i].Points, t.add(bar)...)
+		}
+
+		trackers[i] = t
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if len(trackers) == 0 {
+		delete(s.trackers, symbol)
+	} else {
+		s.trackers[symbol] = trackers
+	}
+
+	return result
+}
+
+// Remove stops updating the symbol's indicators
+func (s *Store) Remove(symbol string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	delete(s.trackers, strings.ToUpper(strings.TrimSpace(symbol)))
+}
+
+// ApplyBar adds a new bar, or replaces one of the latest bars with its update, and returns the changed points
+func (s *Store) ApplyBar(symbol string, bar marketdata.Bar) Updates {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var updates Updates
+
+	for _, t := range s.trackers[symbol] {
+		for _, point := range t.add(bar) {
+			updates = append(updates, Update{Symbol: symbol, Spec: t.spec.String(), Point: point})
+		}
+	}
+
+	return updates
+}
@@ -0,0 +1,86 @@
+package indicator
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"testing"
+	"time"
+)
+
+func minuteBars(n int) []marketdata.Bar {
+	start := time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC)
+	bars := make([]marketdata.Bar, n)
+
+	for i := range bars {
+		bars[i] = marketdata.Bar{Timestamp: start.Add(time.Duration(i) * time.Minute), Close: float64(i + 1)}
+	}
+
+	return bars
+}
+
+func TestApplyBarRecomputesFromLateBar(t *testing.T) {
+	spec, _ := ParseSpec("sma:3")
+	bars := minuteBars(10)
+	store := NewStore()
+	store.Set("AAPL", []Spec{spec}, bars)
+
+	// the bar two minutes ago is updated after the latest bar
+	corrected := bars[7]
+	corrected.Close = 80
+	bars[7] = corrected
+
+	updates := store.ApplyBar("AAPL", corrected)
+	expected := Compute(bars, spec)
+
+	if len(updates) != 3 {
+		t.Fatalf("expected the points from the corrected bar onwards, got %d", len(updates))
+	}
+
+	for i, update := range updates {
+		point := expected.Points[7+i]
+
+		if !update.Point.Time.Equal(point.Time) || update.Point.Values["value"] != point.Values["value"] {
+			t.Fatalf("update %d is %+v, expected %+v", i, update.Point, point)
+		}
+	}
+
+	// the next bar continues from the corrected bars
+	next := minuteBars(11)[10]
+	bars = append(bars, next)
+	expected = Compute(bars, spec)
+	updates = store.ApplyBar("AAPL", next)
+
+	if len(updates) != 1 || updates[0].Point.Values["value"] != expected.Points[10].Values["value"] {
+		t.Fatalf("unexpected update %+v, expected %+v", updates, expected.Points[10])
+	}
+}
+
+func TestApplyBarIgnoresBarsBeforeLateBars(t *testing.T) {
+	spec, _ := ParseSpec("ema:5")
+	bars := minuteBars(LateBars + 10)
+	store := NewStore()
+	series := store.Set("AAPL", []Spec{spec}, bars)
+
+	if len(series[0].Points) != len(bars) {
+		t.Fatalf("expected %d points, got %d", len(bars), len(series[0].Points))
+	}
+
+	if updates := store.ApplyBar("AAPL", bars[5]); len(updates) != 0 {
+		t.Fatalf("expected a bar older than the latest bars to be ignored, got %d updates", len(updates))
+	}
+
+	// the oldest of the latest bars can still be replaced
+	oldest := len(bars) - LateBars
+	corrected := bars[oldest]
+	corrected.Close = 1_000
+	bars[oldest] = corrected
+	expected := Compute(bars, spec)
+	updates := store.ApplyBar("AAPL", corrected)
+
+	if len(updates) != LateBars {
+		t.Fatalf("expected %d updates, got %d", LateBars, len(updates))
+	}
+
+	if last := updates[len(updates)-1].Point.Values["value"]; last != expected.Points[len(bars)-1].Values["value"] {
+		t.Fatalf("expected %v, got %v", expected.Points[len(bars)-1].Values["value"], last)
+	}
+}
@@ -0,0 +1,194 @@
+package performance
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"time"
+)
+
+// Horizon is the point a change is measured from
+type Horizon string
+
+const (
+	// Open is the session's first regular hours bar
+	Open Horizon = "open"
+	// PreviousClose is the previous trading day's close
+	PreviousClose Horizon = "previous_close"
+	// PreMarketOpen is the session's first pre-market bar
+	PreMarketOpen Horizon = "pre_market_open"
+	// OneWeek is the close of the last trading day on or before a week ago
+	OneWeek Horizon = "1W"
+	// OneMonth is the close of the last trading day on or before a month ago
+	OneMonth Horizon = "1M"
+	// ThreeMonths is the close of the last trading day on or before three months ago
+	ThreeMonths Horizon = "3M"
+	// YearToDate is the close of the previous year's last trading day
+	YearToDate Horizon = "YTD"
+	// OneYear is the close of the last trading day on or before a year ago
+	OneYear Horizon = "1Y"
+	// High52Week is the highest high of the past 52 weeks, including the session
+	High52Week Horizon = "52W_high"
+	// Low52Week is the lowest low of the past 52 weeks, including the session
+	Low52Week Horizon = "52W_low"
+)
+
+// Horizons are every horizon, in the order they are returned
+var Horizons = []Horizon{Open, PreviousClose, PreMarketOpen, OneWeek, OneMonth, ThreeMonths, YearToDate, OneYear, High52Week, Low52Week}
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// Anchor is a horizon's price and the trading day it is from. A zero price is an anchor that is not available, such
+// as the open before the session opens or a year ago for a recent listing.
+type Anchor struct {
+	Horizon Horizon `json:"horizon"`
+	Date    string  `json:"date"`
+	Price   float64 `json:"price"`
+}
+
+// Change is the change in the last price since a horizon's anchor
+type Change struct {
+	Anchor
+	Available bool    `json:"available"`
+	Change    float64 `json:"change"`
+	// ChangePercent is the change as a percentage, e.g. 1.5 for 1.5%
+	ChangePercent float64 `json:"changePercent"`
+}
+
+// Performance is a symbol's change since each horizon for a session
+type Performance struct {
+	Symbol  string    `json:"symbol"`
+	Date    string    `json:"date"`
+	Last    float64   `json:"last"`
+	Time    time.Time `json:"time"`
+	Changes []Change  `json:"changes"`
+}
+
+// Performances are the performances that changed since they were last emitted
+type Performances map[string]Performance
+
+func sessionDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+func startOfDate(date string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02", date, newYork)
+
+	return t
+}
+
+// monthsBefore returns the date the number of months before the date, clamped to the end of a shorter month, e.g.
+// a month before March 31 is February 28 or 29
+func monthsBefore(date string, months int) string {
+	t := startOfDate(date)
+	firstOfMonth := time.Date(t.Year(), t.Month()-time.Month(months), 1, 0, 0, 0, 0, newYork)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, newYork).Format("2006-01-02")
+}
+
+// history holds the session's anchors from the trading days before it and the session's first bars. The 52 week high
+// and low exclude the session, which is combined with them as it trades.
+type history struct {
+	day     calendar.Calendar
+	anchors map[Horizon]Anchor
+	// first is the timestamp of the bar the open or pre-market open is from
+	first map[Horizon]time.Time
+}
+
+// closeOnOrBefore returns the close of the last trading day on or before the date, falling back to the latest bar
+// before it when the symbol did not trade that day
+func closeOnOrBefore(date string, tradingDays []calendar.Calendar, closes map[string]float64, bars []marketdata.Bar, horizon Horizon) Anchor {
+	for i := len(tradingDays) - 1; i >= 0; i-- {
+		if tradingDays[i].Date > date {
+			continue
+		}
+
+		if price, ok := closes[tradingDays[i].Date]; ok {
+			return Anchor{Horizon: horizon, Date: tradingDays[i].Date, Price: price}
+		}
+
+		break
+	}
+
+	for i := len(bars) - 1; i >= 0; i-- {
+		if barDate := sessionDate(bars[i].Timestamp); barDate <= date {
+			return Anchor{Horizon: horizon, Date: barDate, Price: bars[i].Close}
+		}
+	}
+
+	return Anchor{Horizon: horizon}
+}
+
+// newHistory calculates the anchors of the session from the daily bars and trading days before it, oldest first
+func newHistory(day calendar.Calendar, dailyBars []marketdata.Bar, tradingDays []calendar.Calendar) *history {
+	h := &history{
+		day:     day,
+		anchors: make(map[Horizon]Anchor, len(Horizons)),
+		first:   make(map[Horizon]time.Time),
+	}
+
+	closes := make(map[string]float64, len(dailyBars))
+
+	for _, b := range dailyBars {
+		closes[sessionDate(b.Timestamp)] = b.Close
+	}
+
+	h.anchors[PreviousClose] = closeOnOrBefore(startOfDate(day.Date).AddDate(0, 0, -1).Format("2006-01-02"), tradingDays, closes, dailyBars, PreviousClose)
+	h.anchors[OneWeek] = closeOnOrBefore(startOfDate(day.Date).AddDate(0, 0, -7).Format("2006-01-02"), tradingDays, closes, dailyBars, OneWeek)
+	h.anchors[OneMonth] = closeOnOrBefore(monthsBefore(day.Date, 1), tradingDays, closes, dailyBars, OneMonth)
+	h.anchors[ThreeMonths] = closeOnOrBefore(monthsBefore(day.Date, 3), tradingDays, closes, dailyBars, ThreeMonths)
+	h.anchors[YearToDate] = closeOnOrBefore(startOfDate(day.Date[:4]+"-01-01").AddDate(0, 0, -1).Format("2006-01-02"), tradingDays, closes, dailyBars, YearToDate)
+	h.anchors[OneYear] = closeOnOrBefore(monthsBefore(day.Date, 12), tradingDays, closes, dailyBars, OneYear)
+
+	// the trading days after a year ago, up to the session
+	yearAgo := monthsBefore(day.Date, 12)
+	high := Anchor{Horizon: High52Week}
+	low := Anchor{Horizon: Low52Week}
+
+	for _, b := range dailyBars {
+		date := sessionDate(b.Timestamp)
+
+		if date <= yearAgo || date >= day.Date {
+			continue
+		}
+
+		if b.High > high.Price {
+			high = Anchor{Horizon: High52Week, Date: date, Price: b.High}
+		}
+
+		if b.Low > 0 && (low.Price == 0 || b.Low < low.Price) {
+			low = Anchor{Horizon: Low52Week, Date: date, Price: b.Low}
+		}
+	}
+
+	h.anchors[High52Week] = high
+	h.anchors[Low52Week] = low
+
+	return h
+}
+
+// applyBar sets the pre-market open or open from the session's first pre-market or regular hours minute bar
+func (h *history) applyBar(b marketdata.Bar) {
+	var horizon Horizon
+
+	switch {
+	case b.Timestamp.Before(h.day.SessionOpen) || !b.Timestamp.Before(h.day.Close):
+		return
+	case b.Timestamp.Before(h.day.Open):
+		horizon = PreMarketOpen
+	default:
+		horizon = Open
+	}
+
+	if first, ok := h.first[horizon]; ok && !b.Timestamp.Before(first) {
+		return
+	}
+
+	h.first[horizon] = b.Timestamp
+	h.anchors[horizon] = Anchor{Horizon: horizon, Date: h.day.Date, Price: b.Open}
+}
@@ -0,0 +1,265 @@
+package performance
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"testing"
+	"time"
+)
+
+// holidays are the weekdays the fixed calendar does not trade
+var holidays = map[string]bool{
+	"2025-04-18": true,
+	"2025-12-25": true,
+	"2026-01-01": true,
+	"2026-02-16": true,
+	"2026-03-24": true,
+}
+
+func at(date string, hour, minute int) time.Time {
+	return startOfDate(date).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+// tradingDays returns a calendar of the weekdays from start to end, except holidays
+func tradingDays(start, end string) []calendar.Calendar {
+	days := make([]calendar.Calendar, 0)
+
+	for day := startOfDate(start); !day.After(startOfDate(end)); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || holidays[date] {
+			continue
+		}
+
+		days = append(days, calendar.Calendar{
+			Date:         date,
+			Open:         at(date, 9, 30),
+			Close:        at(date, 16, 0),
+			SessionOpen:  at(date, 4, 0),
+			SessionClose: at(date, 20, 0),
+		})
+	}
+
+	return days
+}
+
+// dailyBars returns a daily bar for each trading day, except the missing dates, closing 100 higher each day
+func dailyBars(days []calendar.Calendar, missing ...string) ([]marketdata.Bar, map[string]float64) {
+	skip := make(map[string]bool, len(missing))
+
+	for _, date := range missing {
+		skip[date] = true
+	}
+
+	bars := make([]marketdata.Bar, 0, len(days))
+	closes := make(map[string]float64, len(days))
+
+	for i, day := range days {
+		if skip[day.Date] {
+			continue
+		}
+
+		price := 100 + float64(i)
+		closes[day.Date] = price
+		bars = append(bars, marketdata.Bar{Timestamp: startOfDate(day.Date), Open: price, High: price + 1, Low: price - 1, Close: price})
+	}
+
+	return bars, closes
+}
+
+func TestMonthsBefore(t *testing.T) {
+	tests := []struct {
+		date     string
+		months   int
+		expected string
+	}{
+		{"2026-03-31", 1, "2026-02-28"},
+		{"2024-03-31", 1, "2024-02-29"},
+		{"2026-05-31", 3, "2026-02-28"},
+		{"2026-01-15", 1, "2025-12-15"},
+		{"2026-03-31", 12, "2025-03-31"},
+	}
+
+	for _, test := range tests {
+		if actual := monthsBefore(test.date, test.months); actual != test.expected {
+			t.Errorf("%d months before %s: expected %s, got %s", test.months, test.date, test.expected, actual)
+		}
+	}
+}
+
+func TestNewHistoryAnchors(t *testing.T) {
+	days := tradingDays("2025-03-14", "2026-03-31")
+	day := days[len(days)-1]
+	before := days[:len(days)-1]
+
+	tests := []struct {
+		name    string
+		missing []string
+		// expected are the dates of the closes each horizon is anchored to
+		expected map[Horizon]string
+	}{
+		{
+			name: "trading days",
+			expected: map[Horizon]string{
+				PreviousClose: "2026-03-30",
+				// a week ago is a holiday
+				OneWeek: "2026-03-23",
+				// a month ago is clamped to February 28, a Saturday
+				OneMonth:    "2026-02-27",
+				ThreeMonths: "2025-12-31",
+				// the previous year's last trading day
+				YearToDate: "2025-12-31",
+				OneYear:    "2025-03-31",
+			},
+		},
+		{
+			name:    "days without a bar",
+			missing: []string{"2026-02-27", "2025-12-31"},
+			expected: map[Horizon]string{
+				PreviousClose: "2026-03-30",
+				OneWeek:       "2026-03-23",
+				OneMonth:      "2026-02-26",
+				ThreeMonths:   "2025-12-30",
+				YearToDate:    "2025-12-30",
+				OneYear:       "2025-03-31",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		bars, closes := dailyBars(before, test.missing...)
+		h := newHistory(day, bars, before)
+
+		for horizon, date := range test.expected {
+			anchor := h.anchors[horizon]
+
+			if anchor.Date != date || anchor.Price != closes[date] {
+				t.Errorf("%s: expected %s anchored to %s at %.2f, got %+v", test.name, horizon, date, closes[date], anchor)
+			}
+		}
+
+		if h.anchors[Open].Price != 0 || h.anchors[PreMarketOpen].Price != 0 {
+			t.Errorf("%s: expected no open before the session's bars", test.name)
+		}
+	}
+}
+
+func TestNewHistory52WeekRange(t *testing.T) {
+	days := tradingDays("2025-03-14", "2026-03-31")
+	day := days[len(days)-1]
+	before := days[:len(days)-1]
+	bars, _ := dailyBars(before)
+
+	for i := range bars {
+		switch sessionDate(bars[i].Timestamp) {
+		case "2025-03-31":
+			// a year ago is outside of the range
+			bars[i].High = 10_000
+			bars[i].Low = 1
+		case "2025-04-01":
+			bars[i].High = 5_000
+		case "2025-06-02":
+			bars[i].Low = 10
+		}
+	}
+
+	h := newHistory(day, bars, before)
+
+	if high := h.anchors[High52Week]; high.Date != "2025-04-01" || high.Price != 5_000 {
+		t.Fatalf("unexpected 52 week high %+v", high)
+	}
+
+	if low := h.anchors[Low52Week]; low.Date != "2025-06-02" || low.Price != 10 {
+		t.Fatalf("unexpected 52 week low %+v", low)
+	}
+
+	tests := []struct {
+		name         string
+		sessionDate  string
+		high, low    float64
+		expectedHigh Anchor
+		expectedLow  Anchor
+	}{
+		{
+			name:         "within the range",
+			sessionDate:  day.Date,
+			high:         400,
+			low:          300,
+			expectedHigh: Anchor{Horizon: High52Week, Date: "2025-04-01", Price: 5_000},
+			expectedLow:  Anchor{Horizon: Low52Week, Date: "2025-06-02", Price: 10},
+		},
+		{
+			name:         "session extends the range",
+			sessionDate:  day.Date,
+			high:         6_000,
+			low:          5,
+			expectedHigh: Anchor{Horizon: High52Week, Date: day.Date, Price: 6_000},
+			expectedLow:  Anchor{Horizon: Low52Week, Date: day.Date, Price: 5},
+		},
+		{
+			name:         "ticker from another session",
+			sessionDate:  "2026-03-30",
+			high:         6_000,
+			low:          5,
+			expectedHigh: Anchor{Horizon: High52Week, Date: "2025-04-01", Price: 5_000},
+			expectedLow:  Anchor{Horizon: Low52Week, Date: "2025-06-02", Price: 10},
+		},
+	}
+
+	for _, test := range tests {
+		p := h.performance(ticker.Ticker{Symbol: "AAPL", SessionDate: test.sessionDate, Last: 350, High: test.high, Low: test.low})
+
+		for _, change := range p.Changes {
+			if change.Horizon == High52Week && change.Anchor != test.expectedHigh {
+				t.Errorf("%s: expected high %+v, got %+v", test.name, test.expectedHigh, change.Anchor)
+			}
+
+			if change.Horizon == Low52Week && change.Anchor != test.expectedLow {
+				t.Errorf("%s: expected low %+v, got %+v", test.name, test.expectedLow, change.Anchor)
+			}
+		}
+	}
+}
+
+func TestApplyBarSetsOpens(t *testing.T) {
+	days := tradingDays("2026-03-02", "2026-03-31")
+	day := days[len(days)-1]
+	h := newHistory(day, nil, days[:len(days)-1])
+
+	bars := []marketdata.Bar{
+		// before the session and after the close are ignored
+		{Timestamp: at(day.Date, 3, 59), Open: 1},
+		{Timestamp: at(day.Date, 16, 0), Open: 2},
+		{Timestamp: at(day.Date, 8, 0), Open: 10},
+		// an earlier bar arriving late replaces the first
+		{Timestamp: at(day.Date, 7, 0), Open: 9},
+		{Timestamp: at(day.Date, 8, 30), Open: 11},
+		{Timestamp: at(day.Date, 9, 31), Open: 21},
+		{Timestamp: at(day.Date, 9, 30), Open: 20},
+	}
+
+	for _, b := range bars {
+		h.applyBar(b)
+	}
+
+	if open := h.anchors[PreMarketOpen]; open.Price != 9 || open.Date != day.Date {
+		t.Fatalf("unexpected pre-market open %+v", open)
+	}
+
+	if open := h.anchors[Open]; open.Price != 20 || open.Date != day.Date {
+		t.Fatalf("unexpected open %+v", open)
+	}
+
+	p := h.performance(ticker.Ticker{Symbol: "AAPL", SessionDate: day.Date, Last: 22})
+
+	for _, change := range p.Changes {
+		if change.Horizon == Open && (!change.Available || change.Change != 2 || change.ChangePercent != 10) {
+			t.Fatalf("unexpected change since the open %+v", change)
+		}
+
+		if change.Horizon == OneYear && change.Available {
+			t.Fatalf("expected no anchor a year ago, got %+v", change)
+		}
+	}
+}
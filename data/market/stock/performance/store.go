@@ -0,0 +1,278 @@
+package performance
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/stock/bar"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RetryInterval is the time before anchors that failed to load are requested again, doubling after each
+	// consecutive failure up to MaxRetryInterval
+	RetryInterval    = 15 * time.Second
+	MaxRetryInterval = 5 * time.Minute
+)
+
+// tracker keeps a symbol's anchors. requested is the latest session anchors have been requested for and pending are
+// that session's bars streamed until they load. A failed load is retried after a backoff.
+type tracker struct {
+	history   *history
+	requested string
+	pending   []marketdata.Bar
+	loading   bool
+	failures  int
+	retryAt   time.Time
+}
+
+// Store keeps the performance of each tracked symbol current as its last price changes, loading the anchors of a new
+// session from the daily bars and trading calendar when it starts
+type Store struct {
+	mut                sync.Mutex
+	barRepository      *bar.Repository
+	calendarRepository *calendar.Repository
+	trackers           map[string]*tracker
+}
+
+func NewStore(barRepository *bar.Repository, calendarRepository *calendar.Repository) *Store {
+	return &Store{
+		barRepository:      barRepository,
+		calendarRepository: calendarRepository,
+		trackers:           make(map[string]*tracker),
+	}
+}
+
+// load calculates the symbol's anchors for the current trading day, or the previous one if today is not a trading
+// day, from split adjusted daily bars
+func (s *Store) load(symbol string) (*history, error) {
+	today := sessionDate(time.Now())
+
+	// allow for the holidays around a year ago
+	tradingDays, err := s.calendarRepository.Between(startOfDate(monthsBefore(today, 12)).AddDate(0, 0, -14).Format("2006-01-02"), today)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tradingDays) == 0 {
+		return nil, bar.ErrNoSessions
+	}
+
+	day := tradingDays[len(tradingDays)-1]
+
+	dailyBars, err := s.barRepository.Bars(symbol, marketdata.OneDay, startOfDate(tradingDays[0].Date), day.SessionOpen, marketdata.Split)
+
+	if err != nil {
+		return nil, err
+	}
+
+	before := make([]marketdata.Bar, 0, len(dailyBars))
+
+	for _, b := range dailyBars {
+		if sessionDate(b.Timestamp) < day.Date {
+			before = append(before, b)
+		}
+	}
+
+	return newHistory(day, before, tradingDays[:len(tradingDays)-1]), nil
+}
+
+// performance measures the ticker's last price against each anchor, extending the 52 week high and low with the
+// session's
+func (h *history) performance(t ticker.Ticker) Performance {
+	p := Performance{
+		Symbol:  t.Symbol,
+		Date:    h.day.Date,
+		Last:    t.Last,
+		Time:    t.LastTimestamp,
+		Changes: make([]Change, len(Horizons)),
+	}
+
+	for i, horizon := range Horizons {
+		anchor, ok := h.anchors[horizon]
+
+		if !ok {
+			anchor = Anchor{Horizon: horizon}
+		}
+
+		if t.SessionDate == h.day.Date {
+			if horizon == High52Week && t.High > anchor.Price {
+				anchor = Anchor{Horizon: horizon, Date: h.day.Date, Price: t.High}
+			}
+
+			if horizon == Low52Week && t.Low > 0 && (anchor.Price == 0 || t.Low < anchor.Price) {
+				anchor = Anchor{Horizon: horizon, Date: h.day.Date, Price: t.Low}
+			}
+		}
+
+		change := Change{Anchor: anchor}
+
+		if anchor.Price > 0 && t.Last > 0 {
+			change.Available = true
+			change.Change = t.Last - anchor.Price
+			change.ChangePercent = change.Change / anchor.Price * 100
+		}
+
+		p.Changes[i] = change
+	}
+
+	return p
+}
+
+// Set tracks the symbol's performance, taking the session's open and pre-market open from its minute bars, and
+// returns its performance as of the ticker
+func (s *Store) Set(symbol string, bars []marketdata.Bar, t ticker.Ticker) (Performance, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	h, err := s.load(symbol)
+
+	if err != nil {
+		return Performance{}, err
+	}
+
+	for _, b := range bars {
+		h.applyBar(b)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.trackers[symbol] = &tracker{
+		history:   h,
+		requested: h.day.Date,
+	}
+
+	t.Symbol = symbol
+
+	return h.performance(t), nil
+}
+
+// Remove stops tracking the symbol's performance
+func (s *Store) Remove(symbol string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	delete(s.trackers, strings.ToUpper(strings.TrimSpace(symbol)))
+}
+
+// ApplyBar takes the session's open or pre-market open from the symbol's minute bar, if it is the first
+func (s *Store) ApplyBar(symbol string, b marketdata.Bar) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	tracked, ok := s.trackers[symbol]
+
+	if !ok {
+		return
+	}
+
+	if date := sessionDate(b.Timestamp); date > tracked.history.day.Date {
+		s.advance(symbol, tracked, date)
+
+		if date == tracked.requested {
+			tracked.pending = append(tracked.pending, b)
+		}
+
+		return
+	}
+
+	tracked.history.applyBar(b)
+}
+
+// ApplyTickers returns the performance of each tracked symbol in the tickers. A ticker from a later session loads
+// that session's anchors in the background and is skipped until they are loaded.
+func (s *Store) ApplyTickers(tickers ticker.Tickers) Performances {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	performances := make(Performances)
+
+	for symbol, t := range tickers {
+		tracked, ok := s.trackers[symbol]
+
+		if !ok || t.Last == 0 {
+			continue
+		}
+
+		if t.SessionDate > tracked.history.day.Date {
+			s.advance(symbol, tracked, t.SessionDate)
+			continue
+		}
+
+		performances[symbol] = tracked.history.performance(t)
+	}
+
+	return performances
+}
+
+// advance loads the anchors of a later session in the background, unless they are loading or a failed load is
+// backing off
+func (s *Store) advance(symbol string, tracked *tracker, date string) {
+	if date > tracked.requested {
+		tracked.requested = date
+		tracked.pending = nil
+	}
+
+	if tracked.loading || time.Now().Before(tracked.retryAt) {
+		return
+	}
+
+	tracked.loading = true
+	go s.reload(symbol, tracked)
+}
+
+// reload replaces the tracker's anchors with those of the latest session, applying the bars streamed meanwhile
+func (s *Store) reload(symbol string, tracked *tracker) {
+	h, err := s.load(symbol)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	tracked.loading = false
+
+	if err != nil {
+		log.Printf("failed to load the performance anchors for %s: %v", symbol, err)
+		tracked.backOff(time.Now())
+		return
+	}
+
+	if s.trackers[symbol] != tracked {
+		return
+	}
+
+	// the calendar does not have the later session yet
+	if h.day.Date <= tracked.history.day.Date {
+		tracked.backOff(time.Now())
+		return
+	}
+
+	tracked.failures = 0
+	tracked.retryAt = time.Time{}
+
+	for _, b := range tracked.pending {
+		h.applyBar(b)
+	}
+
+	tracked.history = h
+	tracked.pending = nil
+}
+
+// backOff delays the next load after a failed one, doubling the delay with each consecutive failure
+func (t *tracker) backOff(failedAt time.Time) {
+	t.failures++
+	delay := RetryInterval
+
+	for i := 1; i < t.failures && delay < MaxRetryInterval; i++ {
+		delay *= 2
+	}
+
+	if delay > MaxRetryInterval {
+		delay = MaxRetryInterval
+	}
+
+	t.retryAt = failedAt.Add(delay)
+}
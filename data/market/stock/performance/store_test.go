@@ -0,0 +1,76 @@
+package performance
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/market/stock/bar/bartest"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"testing"
+	"time"
+)
+
+// loaded waits for the symbol's anchors to finish loading
+func loaded(t *testing.T, s *Store, symbol string) *tracker {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.mut.Lock()
+		tracked := s.trackers[symbol]
+		loading := tracked.loading
+		s.mut.Unlock()
+
+		if !loading {
+			return tracked
+		}
+	}
+
+	t.Fatal("anchors did not finish loading")
+
+	return nil
+}
+
+func TestFailedLoadBacksOff(t *testing.T) {
+	db := testutil.DB(t)
+	market := fake.NewMarket(fake.Options{})
+	s := NewStore(bartest.NewRepository(t, db, market), testutil.CalendarRepository(t, db, market))
+
+	p, err := s.Set("AAPL", nil, ticker.Ticker{Last: 100})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the calendar can no longer be read, so the next session's anchors cannot be loaded
+	testutil.CloseDB(t, db)
+
+	next := startOfDate(p.Date).AddDate(0, 0, 1)
+	s.ApplyBar("AAPL", marketdata.Bar{Timestamp: next.Add(8 * time.Hour), Open: 1})
+
+	tracked := loaded(t, s, "AAPL")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if tracked.history.day.Date != p.Date || tracked.failures != 1 || !tracked.retryAt.After(time.Now()) {
+		t.Fatalf("expected the failed load to back off, got %d failures until %s", tracked.failures, tracked.retryAt)
+	}
+
+	if len(tracked.pending) != 1 {
+		t.Fatalf("expected the session's bar to be kept for the retry, got %d", len(tracked.pending))
+	}
+
+	// no load while backing off
+	s.advance("AAPL", tracked, tracked.requested)
+
+	if tracked.loading {
+		t.Fatal("expected no load while backing off")
+	}
+
+	// bars of an earlier pending session are dropped when a later one starts
+	s.advance("AAPL", tracked, startOfDate(p.Date).AddDate(0, 0, 2).Format("2006-01-02"))
+
+	if tracked.pending != nil {
+		t.Fatalf("expected the pending bars to be cleared, got %d", len(tracked.pending))
+	}
+}
@@ -0,0 +1,208 @@
+package rvol
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/stock/bar"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDays is the number of trading days averaged when none are given
+const DefaultDays = 20
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// RelativeVolume is a symbol's volume since the session open compared with its average volume by the same time of
+// day. Time is the start of the latest minute bar and Average is the average cumulative volume at the end of that
+// minute.
+type RelativeVolume struct {
+	Symbol  string    `json:"symbol"`
+	Days    int       `json:"days"`
+	Date    string    `json:"date"`
+	Time    time.Time `json:"time"`
+	Volume  uint64    `json:"volume"`
+	Average float64   `json:"average"`
+	RVOL    float64   `json:"rvol"`
+}
+
+func sessionDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+// tracker keeps a symbol's minute volumes for the latest session. requested is the latest session a profile has
+// been built for, so that a new session rebuilds the profile once, retrying on the next bar if the rebuild fails.
+type tracker struct {
+	days       int
+	profile    *bar.VolumeProfile
+	date       string
+	volumes    map[time.Time]uint64
+	latest     time.Time
+	requested  string
+	rebuilding bool
+}
+
+// add records the bar's volume, replacing the volume of an updated bar, and reports whether it is from the latest
+// session
+func (t *tracker) add(b marketdata.Bar) bool {
+	date := sessionDate(b.Timestamp)
+
+	if date < t.date {
+		return false
+	}
+
+	if date > t.date {
+		t.date = date
+		t.volumes = make(map[time.Time]uint64)
+		t.latest = time.Time{}
+	}
+
+	t.volumes[b.Timestamp] = b.Volume
+
+	if b.Timestamp.After(t.latest) {
+		t.latest = b.Timestamp
+	}
+
+	return true
+}
+
+// result compares the volume up to the latest bar with the profile, unless the profile is not for the session
+func (t *tracker) result(symbol string) (RelativeVolume, bool) {
+	if t.profile == nil || t.profile.Date != t.date {
+		return RelativeVolume{}, false
+	}
+
+	if _, ok := t.profile.Minute(t.latest); !ok {
+		return RelativeVolume{}, false
+	}
+
+	r := RelativeVolume{
+		Symbol:  symbol,
+		Days:    t.days,
+		Date:    t.date,
+		Time:    t.latest,
+		Average: t.profile.Average(t.latest),
+	}
+
+	for timestamp, volume := range t.volumes {
+		if _, ok := t.profile.Minute(timestamp); ok && !timestamp.After(t.latest) {
+			r.Volume += volume
+		}
+	}
+
+	if r.Average > 0 {
+		r.RVOL = float64(r.Volume) / r.Average
+	}
+
+	return r, true
+}
+
+// Store keeps the relative volume of each tracked symbol current as minute bars are streamed, rebuilding its volume
+// profile from the Repository when a new session starts
+type Store struct {
+	mut        sync.Mutex
+	repository *bar.Repository
+	trackers   map[string]*tracker
+}
+
+func NewStore(repository *bar.Repository) *Store {
+	return &Store{
+		repository: repository,
+		trackers:   make(map[string]*tracker),
+	}
+}
+
+// Set tracks the symbol's relative volume against the average of the number of trading days before the session,
+// seeded from the session's minute bars, and returns the current relative volume
+func (s *Store) Set(symbol string, days int, bars []marketdata.Bar) (RelativeVolume, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	if days < 1 {
+		days = DefaultDays
+	}
+
+	if days > bar.MaxProfileDays {
+		days = bar.MaxProfileDays
+	}
+
+	profile, err := s.repository.VolumeProfile(symbol, days)
+
+	if err != nil {
+		return RelativeVolume{}, err
+	}
+
+	t := &tracker{
+		days:      days,
+		profile:   profile,
+		date:      profile.Date,
+		volumes:   make(map[time.Time]uint64),
+		requested: profile.Date,
+	}
+
+	for _, b := range bars {
+		t.add(b)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.trackers[symbol] = t
+
+	r, ok := t.result(symbol)
+
+	if !ok {
+		// nothing has traded yet this session
+		r = RelativeVolume{Symbol: symbol, Days: days, Date: t.date}
+	}
+
+	return r, nil
+}
+
+// Remove stops tracking the symbol's relative volume
+func (s *Store) Remove(symbol string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	delete(s.trackers, strings.ToUpper(strings.TrimSpace(symbol)))
+}
+
+// ApplyBar adds a new minute bar, or replaces an updated one, and returns the symbol's relative volume if tracked
+func (s *Store) ApplyBar(symbol string, b marketdata.Bar) (RelativeVolume, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t, ok := s.trackers[symbol]
+
+	if !ok || !t.add(b) {
+		return RelativeVolume{}, false
+	}
+
+	if t.date > t.requested && !t.rebuilding {
+		t.rebuilding = true
+		go s.rebuild(symbol, t, t.date)
+	}
+
+	return t.result(symbol)
+}
+
+// rebuild replaces the tracker's profile with one for the session date
+func (s *Store) rebuild(symbol string, t *tracker, date string) {
+	profile, err := s.repository.VolumeProfile(symbol, t.days)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t.rebuilding = false
+
+	if err != nil {
+		log.Printf("failed to rebuild the volume profile for %s: %v", symbol, err)
+		return
+	}
+
+	t.requested = date
+
+	if s.trackers[symbol] == t && profile.Date > t.profile.Date {
+		t.profile = profile
+	}
+}
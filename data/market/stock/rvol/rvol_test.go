@@ -0,0 +1,63 @@
+package rvol
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/market/stock/bar/bartest"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"testing"
+	"time"
+)
+
+// rebuilt waits for the symbol's profile rebuild to finish, returning the session it was last built for
+func rebuilt(t *testing.T, s *Store, symbol string) string {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.mut.Lock()
+		tracker := s.trackers[symbol]
+		rebuilding, requested := tracker.rebuilding, tracker.requested
+		s.mut.Unlock()
+
+		if !rebuilding {
+			return requested
+		}
+	}
+
+	t.Fatal("profile rebuild did not finish")
+
+	return ""
+}
+
+func TestFailedRebuildIsRetried(t *testing.T) {
+	db := testutil.DB(t)
+	market := fake.NewMarket(fake.Options{})
+	s := NewStore(bartest.NewRepository(t, db, market))
+	r, err := s.Set("AAPL", 5, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the calendar can no longer be read, so the next session's profile cannot be built
+	testutil.CloseDB(t, db)
+
+	date, _ := time.ParseInLocation("2006-01-02", r.Date, newYork)
+	next := date.AddDate(0, 0, 1).Add(10 * time.Hour)
+
+	s.ApplyBar("AAPL", marketdata.Bar{Timestamp: next, Volume: 100})
+
+	if requested := rebuilt(t, s, "AAPL"); requested != r.Date {
+		t.Fatalf("expected the failed session to be retried, requested is %s", requested)
+	}
+
+	s.mut.Lock()
+	s.repository = bartest.NewRepository(t, testutil.DB(t), market)
+	s.mut.Unlock()
+
+	s.ApplyBar("AAPL", marketdata.Bar{Timestamp: next.Add(time.Minute), Volume: 100})
+
+	if requested := rebuilt(t, s, "AAPL"); requested != sessionDate(next) {
+		t.Fatalf("expected the next bar to rebuild the profile for %s, requested is %s", sessionDate(next), requested)
+	}
+}
@@ -0,0 +1,403 @@
+package sales
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/stock/conditions"
+	"github.com/phoobynet/buffalo/data/market/stock/venues"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the number of recent trades kept per symbol
+const DefaultCapacity = 5_000
+
+// cancel/error actions sent with a stream.TradeCancelError
+const (
+	CancelAction = "X"
+	ErrorAction  = "E"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// Trade is a print held by the ledger, amended in place by corrections, cancels and errors
+type Trade struct {
+	// Seq orders the symbol's trades in the order they were received
+	Seq        uint64    `json:"seq"`
+	ID         int64     `json:"i"`
+	Price      float64   `json:"p"`
+	Size       uint32    `json:"s"`
+	Exchange   string    `json:"x"`
+	Conditions []string  `json:"c"`
+	Tape       string    `json:"z"`
+	Timestamp  time.Time `json:"t"`
+	// Eligibility is whether the trade's conditions allow it to update the last sale, high/low and volume
+	Eligibility conditions.Eligibility `json:"eligibility"`
+	Corrected   bool                   `json:"corrected,omitempty"`
+	Canceled    bool                   `json:"canceled,omitempty"`
+}
+
+// Summary is the symbol's last sale and day totals after applying every amendment. Only trades whose conditions
+// allow it update the last sale, high/low and volume.
+type Summary struct {
+	Symbol        string    `json:"symbol"`
+	LastPrice     float64   `json:"lastPrice"`
+	LastTradeID   int64     `json:"lastTradeId"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	DayHigh       float64   `json:"dayHigh"`
+	DayLow        float64   `json:"dayLow"`
+	DayVolume     uint64    `json:"dayVolume"`
+	TradeCount    uint64    `json:"tradeCount"`
+	// DroppedTrades is the number of trades discarded by the stream before reaching the ledger, missing from the totals
+	DroppedTrades uint64 `json:"droppedTrades"`
+}
+
+// Amendment describes a correction, cancel or error applied to a previously received trade
+type Amendment struct {
+	Symbol    string  `json:"symbol"`
+	Kind      string  `json:"kind"`
+	Original  *Trade  `json:"original,omitempty"`
+	Corrected *Trade  `json:"corrected,omitempty"`
+	Found     bool    `json:"found"`
+	Summary   Summary `json:"summary"`
+}
+
+// Ledger maintains recent trades and the day's totals per symbol
+type Ledger struct {
+	mut      sync.RWMutex
+	capacity int
+	books    map[string]*book
+}
+
+type book struct {
+	symbol      string
+	date        string
+	trades      *ring
+	nextSeq     uint64
+	sale        conditions.LastSale
+	lastTradeID int64
+	tradeCount  uint64
+	venues      venues.Tally
+}
+
+func NewLedger(capacity int) *Ledger {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Ledger{
+		capacity: capacity,
+		books:    make(map[string]*book),
+	}
+}
+
+func tradingDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+// book returns the symbol's book, starting a new one when the trading day changes
+func (l *Ledger) book(symbol string, timestamp time.Time) *book {
+	date := tradingDate(timestamp)
+	b, ok := l.books[symbol]
+
+	if !ok || b.date < date {
+		b = &book{
+			symbol: symbol,
+			date:   date,
+			trades: newRing(l.capacity),
+			venues: make(venues.Tally),
+		}
+		l.books[symbol] = b
+	}
+
+	return b
+}
+
+func (b *book) summary() Summary {
+	return Summary{
+		Symbol:        b.symbol,
+		LastPrice:     b.sale.Price,
+		LastTradeID:   b.lastTradeID,
+		LastTimestamp: b.sale.Timestamp,
+		DayHigh:       b.sale.High,
+		DayLow:        b.sale.Low,
+		DayVolume:     b.sale.Volume,
+		TradeCount:    b.tradeCount,
+	}
+}
+
+// apply adds the trade to the day's totals
+func (b *book) apply(trade *Trade) {
+	previous := b.sale.Timestamp
+	trade.Eligibility = b.sale.Apply(trade.Tape, trade.Conditions, trade.Price, trade.Size, trade.Timestamp)
+
+	if trade.Eligibility.UpdatesLast && !trade.Timestamp.Before(previous) {
+		b.lastTradeID = trade.ID
+	}
+
+	b.venues.Add(trade.Exchange, trade.Size, trade.Eligibility.UpdatesVolume)
+	b.tradeCount++
+}
+
+// ApplyTrade records the trade, returning the recorded trade and the symbol's updated summary
+func (l *Ledger) ApplyTrade(streamTrade stream.Trade) (Trade, Summary) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	b := l.book(streamTrade.Symbol, streamTrade.Timestamp)
+	b.nextSeq++
+
+	trade := &Trade{
+		Seq:        b.nextSeq,
+		ID:         streamTrade.ID,
+		Price:      streamTrade.Price,
+		Size:       streamTrade.Size,
+		Exchange:   streamTrade.Exchange,
+		Conditions: streamTrade.Conditions,
+		Tape:       streamTrade.Tape,
+		Timestamp:  streamTrade.Timestamp,
+	}
+
+	b.apply(trade)
+	b.trades.push(trade)
+
+	return *trade, b.summary()
+}
+
+// find returns the most recent trade with the given ID on the given exchange
+func (b *book) find(id int64, exchange string) *Trade {
+	for i := b.trades.len() - 1; i >= 0; i-- {
+		if trade := b.trades.at(i); trade.ID == id && trade.Exchange == exchange {
+			return trade
+		}
+	}
+
+	return nil
+}
+
+// complete returns true if the book still holds every trade of the day
+func (b *book) complete() bool {
+	return uint64(b.trades.len()) == b.nextSeq
+}
+
+// rebuild recalculates the day's totals from every trade that has not been canceled
+func (b *book) rebuild() {
+	b.sale = conditions.LastSale{}
+	b.lastTradeID = 0
+	b.tradeCount = 0
+	b.venues = make(venues.Tally)
+
+	for i := 0; i < b.trades.len(); i++ {
+		if trade := b.trades.at(i); !trade.Canceled {
+			b.apply(trade)
+		}
+	}
+}
+
+// refreshLast recomputes the last sale from the latest eligible trade that has not been canceled. It is used
+// instead of rebuild once the oldest trades of the day have been dropped, leaving the high and low as they were.
+func (b *book) refreshLast() {
+	b.sale.Price = 0
+	b.sale.Timestamp = time.Time{}
+	b.lastTradeID = 0
+
+	for i := b.trades.len() - 1; i >= 0; i-- {
+		if trade := b.trades.at(i); !trade.Canceled && trade.Eligibility.UpdatesLast {
+			b.sale.Price = trade.Price
+			b.sale.Timestamp = trade.Timestamp
+			b.lastTradeID = trade.ID
+			return
+		}
+	}
+}
+
+func (b *book) subtractVolume(exchange string, size uint32, eligibility conditions.Eligibility) {
+	if eligibility.UpdatesVolume && b.sale.Volume >= uint64(size) {
+		b.sale.Volume -= uint64(size)
+	}
+
+	b.venues.SubtractVolume(exchange, size, eligibility.UpdatesVolume)
+}
+
+func (b *book) addVolume(exchange string, size uint32, eligibility conditions.Eligibility) {
+	if eligibility.UpdatesVolume {
+		b.sale.Volume += uint64(size)
+	}
+
+	b.venues.AddVolume(exchange, size, eligibility.UpdatesVolume)
+}
+
+// ApplyCorrection replaces the original trade's price, size and conditions with the corrected values
+func (l *Ledger) ApplyCorrection(correction stream.TradeCorrection) Amendment {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	b := l.book(correction.Symbol, correction.Timestamp)
+
+	amendment := Amendment{
+		Symbol: correction.Symbol,
+		Kind:   "correction",
+	}
+
+	trade := b.find(correction.OriginalID, correction.Exchange)
+
+	if trade == nil {
+		// the original is older than the ledger, so only the day volume can be amended
+		b.subtractVolume(correction.Exchange, correction.OriginalSize, conditions.Evaluate(correction.Tape, correction.OriginalConditions))
+		b.addVolume(correction.Exchange, correction.CorrectedSize, conditions.Evaluate(correction.Tape, correction.CorrectedConditions))
+
+		amendment.Summary = b.summary()
+
+		return amendment
+	}
+
+	original := *trade
+
+	trade.ID = correction.CorrectedID
+	trade.Price = correction.CorrectedPrice
+	trade.Size = correction.CorrectedSize
+	trade.Conditions = correction.CorrectedConditions
+	trade.Eligibility = conditions.Evaluate(trade.Tape, trade.Conditions)
+	trade.Corrected = true
+
+	if b.complete() {
+		b.rebuild()
+	} else if !trade.Canceled {
+		b.subtractVolume(original.Exchange, original.Size, original.Eligibility)
+		b.addVolume(trade.Exchange, trade.Size, trade.Eligibility)
+
+		b.refreshLast()
+	}
+
+	corrected := *trade
+	amendment.Found = true
+	amendment.Original = &original
+	amendment.Corrected = &corrected
+	amendment.Summary = b.summary()
+
+	return amendment
+}
+
+// ApplyCancelError cancels the trade, removing it from the day's totals and the last sale
+func (l *Ledger) ApplyCancelError(cancelError stream.TradeCancelError) Amendment {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	b := l.book(cancelError.Symbol, cancelError.Timestamp)
+
+	amendment := Amendment{
+		Symbol: cancelError.Symbol,
+		Kind:   "cancel",
+	}
+
+	if cancelError.CancelErrorAction == ErrorAction {
+		amendment.Kind = "error"
+	}
+
+	trade := b.find(cancelError.ID, cancelError.Exchange)
+
+	if trade == nil {
+		b.subtractVolume(cancelError.Exchange, cancelError.Size, conditions.Eligibility{UpdatesVolume: true})
+		amendment.Summary = b.summary()
+
+		return amendment
+	}
+
+	original := *trade
+
+	if !trade.Canceled {
+		trade.Canceled = true
+
+		if b.complete() {
+			b.rebuild()
+		} else {
+			b.subtractVolume(trade.Exchange, trade.Size, trade.Eligibility)
+			b.venues.Remove(trade.Exchange)
+			b.tradeCount--
+			b.refreshLast()
+		}
+	}
+
+	amendment.Found = true
+	amendment.Original = &original
+	amendment.Summary = b.summary()
+
+	return amendment
+}
+
+// Summary returns the symbol's last sale and day totals
+func (l *Ledger) Summary(symbol string) Summary {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+
+	if b, ok := l.books[symbol]; ok {
+		return b.summary()
+	}
+
+	return Summary{Symbol: symbol}
+}
+
+// Venues returns the symbol's volume and trade count by venue for the day
+func (l *Ledger) Venues(symbol string) venues.Breakdown {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+
+	if b, ok := l.books[symbol]; ok {
+		return b.venues.Breakdown(symbol)
+	}
+
+	return venues.Tally{}.Breakdown(symbol)
+}
+
+// Page is a page of a symbol's time and sales, newest first
+type Page struct {
+	Symbol string  `json:"symbol"`
+	Trades []Trade `json:"trades"`
+	// Before is the cursor for the next, older page, or zero if there are no older trades
+	Before uint64 `json:"before"`
+}
+
+// TimeAndSales returns up to limit of the symbol's most recent trades received before the given sequence
+// number, newest first. A before of zero starts from the latest trade.
+func (l *Ledger) TimeAndSales(symbol string, limit int, before uint64) Page {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+
+	page := Page{
+		Symbol: symbol,
+		Trades: []Trade{},
+	}
+
+	b, ok := l.books[symbol]
+
+	if !ok || limit <= 0 {
+		return page
+	}
+
+	i := b.trades.len() - 1
+
+	if before > 0 {
+		for i >= 0 && b.trades.at(i).Seq >= before {
+			i--
+		}
+	}
+
+	for ; i >= 0 && len(page.Trades) < limit; i-- {
+		page.Trades = append(page.Trades, *b.trades.at(i))
+	}
+
+	if i >= 0 && len(page.Trades) > 0 {
+		page.Before = page.Trades[len(page.Trades)-1].Seq
+	}
+
+	return page
+}
+
+// Prints are the trades received per symbol since they were last emitted
+type Prints map[string][]Trade
@@ -0,0 +1,201 @@
+package sales
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"testing"
+	"time"
+)
+
+func at(minute int) time.Time {
+	return time.Date(2026, 10, 16, 10, minute, 0, 0, newYork)
+}
+
+func trade(id int64, exchange string, price float64, size uint32, minute int, conditions ...string) stream.Trade {
+	return stream.Trade{
+		ID:         id,
+		Symbol:     "AAPL",
+		Exchange:   exchange,
+		Price:      price,
+		Size:       size,
+		Timestamp:  at(minute),
+		Conditions: conditions,
+		Tape:       "C",
+	}
+}
+
+func correction(originalID, correctedID int64, exchange string, original, corrected stream.Trade) stream.TradeCorrection {
+	return stream.TradeCorrection{
+		Symbol:              "AAPL",
+		Exchange:            exchange,
+		OriginalID:          originalID,
+		OriginalPrice:       original.Price,
+		OriginalSize:        original.Size,
+		OriginalConditions:  original.Conditions,
+		CorrectedID:         correctedID,
+		CorrectedPrice:      corrected.Price,
+		CorrectedSize:       corrected.Size,
+		CorrectedConditions: corrected.Conditions,
+		Tape:                "C",
+		Timestamp:           at(30),
+	}
+}
+
+func cancel(id int64, exchange string, size uint32, action string) stream.TradeCancelError {
+	return stream.TradeCancelError{
+		Symbol:            "AAPL",
+		ID:                id,
+		Exchange:          exchange,
+		Size:              size,
+		CancelErrorAction: action,
+		Tape:              "C",
+		Timestamp:         at(30),
+	}
+}
+
+// expectSummary compares the summary's last sale and totals
+func expectSummary(t *testing.T, name string, actual Summary, lastTradeID int64, last, high, low float64, volume, tradeCount uint64) {
+	t.Helper()
+
+	if actual.LastTradeID != lastTradeID || actual.LastPrice != last || actual.DayHigh != high || actual.DayLow != low ||
+		actual.DayVolume != volume || actual.TradeCount != tradeCount {
+		t.Fatalf("%s: expected last %d at %.2f, high %.2f, low %.2f, volume %d and %d trades, got %+v",
+			name, lastTradeID, last, high, low, volume, tradeCount, actual)
+	}
+}
+
+func TestApplyTrade(t *testing.T) {
+	l := NewLedger(10)
+
+	l.ApplyTrade(trade(1, "Q", 100, 100, 0))
+	l.ApplyTrade(trade(2, "D", 101, 200, 2))
+	// an out of sequence print only updates the high/low and volume
+	l.ApplyTrade(trade(3, "Q", 99, 50, 3, "Z"))
+	// as does a late regular print
+	recorded, summary := l.ApplyTrade(trade(4, "Q", 102, 10, 1))
+
+	if recorded.Seq != 4 || !recorded.Eligibility.UpdatesLast {
+		t.Fatalf("unexpected trade %+v", recorded)
+	}
+
+	expectSummary(t, "trades", summary, 2, 101, 102, 99, 360, 4)
+
+	// a trade from the next day starts a new book
+	next := trade(5, "Q", 110, 100, 0)
+	next.Timestamp = next.Timestamp.AddDate(0, 0, 3)
+	recorded, summary = l.ApplyTrade(next)
+
+	if recorded.Seq != 1 {
+		t.Fatalf("expected the next day's first trade, got %+v", recorded)
+	}
+
+	expectSummary(t, "next day", summary, 5, 110, 110, 110, 100, 1)
+}
+
+func TestApplyCorrection(t *testing.T) {
+	l := NewLedger(10)
+
+	l.ApplyTrade(trade(1, "Q", 100, 100, 0))
+	l.ApplyTrade(trade(2, "D", 101, 200, 1))
+	l.ApplyTrade(trade(3, "Q", 102, 50, 2))
+
+	amendment := l.ApplyCorrection(correction(3, 4, "Q", trade(3, "Q", 102, 50, 2), trade(4, "Q", 99, 60, 2)))
+
+	if !amendment.Found || amendment.Kind != "correction" || amendment.Original.Price != 102 || amendment.Corrected.ID != 4 ||
+		!amendment.Corrected.Corrected {
+		t.Fatalf("unexpected amendment %+v", amendment)
+	}
+
+	// the day is rebuilt, so the corrected print no longer sets the high
+	expectSummary(t, "corrected price", amendment.Summary, 4, 99, 101, 99, 360, 3)
+
+	amendment = l.ApplyCorrection(correction(2, 2, "D", trade(2, "D", 101, 200, 1), trade(2, "D", 101, 200, 1, "I")))
+
+	if !amendment.Corrected.Eligibility.UpdatesVolume || amendment.Corrected.Eligibility.UpdatesHighLow {
+		t.Fatalf("expected an odd lot, got %+v", amendment.Corrected.Eligibility)
+	}
+
+	expectSummary(t, "corrected to an odd lot", amendment.Summary, 4, 99, 100, 99, 360, 3)
+
+	// the same ID on another exchange is a different trade, older than the ledger
+	amendment = l.ApplyCorrection(correction(1, 5, "P", trade(1, "P", 100, 10, 0), trade(5, "P", 100, 30, 0)))
+
+	if amendment.Found || amendment.Original != nil {
+		t.Fatalf("expected the original to be missing, got %+v", amendment)
+	}
+
+	expectSummary(t, "missing original", amendment.Summary, 4, 99, 100, 99, 380, 3)
+}
+
+func TestApplyCancelError(t *testing.T) {
+	l := NewLedger(10)
+
+	l.ApplyTrade(trade(1, "Q", 100, 100, 0))
+	l.ApplyTrade(trade(2, "D", 105, 200, 1, "I"))
+	l.ApplyTrade(trade(3, "Q", 102, 50, 2))
+
+	amendment := l.ApplyCancelError(cancel(3, "Q", 50, CancelAction))
+
+	if !amendment.Found || amendment.Kind != "cancel" || amendment.Original.Canceled {
+		t.Fatalf("unexpected amendment %+v", amendment)
+	}
+
+	// the odd lot does not update the last sale, so the first trade is the last eligible one
+	expectSummary(t, "canceled", amendment.Summary, 1, 100, 100, 100, 300, 2)
+
+	amendment = l.ApplyCancelError(cancel(3, "Q", 50, ErrorAction))
+
+	if !amendment.Found || amendment.Kind != "error" || !amendment.Original.Canceled {
+		t.Fatalf("unexpected amendment %+v", amendment)
+	}
+
+	expectSummary(t, "canceled twice", amendment.Summary, 1, 100, 100, 100, 300, 2)
+
+	amendment = l.ApplyCancelError(cancel(9, "Q", 40, CancelAction))
+
+	if amendment.Found {
+		t.Fatalf("expected the trade to be missing, got %+v", amendment)
+	}
+
+	expectSummary(t, "missing trade", amendment.Summary, 1, 100, 100, 100, 260, 2)
+}
+
+func TestAmendmentsAfterTradesAreDropped(t *testing.T) {
+	l := NewLedger(2)
+
+	l.ApplyTrade(trade(1, "Q", 100, 100, 0))
+	l.ApplyTrade(trade(2, "Q", 105, 100, 1))
+	_, summary := l.ApplyTrade(trade(3, "Q", 103, 100, 2))
+
+	expectSummary(t, "trades", summary, 3, 103, 105, 100, 300, 3)
+
+	// without the first trade the day cannot be rebuilt, so the high and low are kept
+	amendment := l.ApplyCancelError(cancel(3, "Q", 100, CancelAction))
+	expectSummary(t, "canceled", amendment.Summary, 2, 105, 105, 100, 200, 2)
+
+	amendment = l.ApplyCorrection(correction(2, 4, "Q", trade(2, "Q", 105, 100, 1), trade(4, "Q", 104, 50, 1)))
+	expectSummary(t, "corrected", amendment.Summary, 4, 104, 105, 100, 150, 2)
+
+	// a correction of a canceled trade leaves the totals alone
+	amendment = l.ApplyCorrection(correction(3, 5, "Q", trade(3, "Q", 103, 100, 2), trade(5, "Q", 90, 500, 2)))
+	expectSummary(t, "corrected after the cancel", amendment.Summary, 4, 104, 105, 100, 150, 2)
+}
+
+func TestVenuesFollowAmendments(t *testing.T) {
+	for _, capacity := range []int{10, 2} {
+		l := NewLedger(capacity)
+
+		l.ApplyTrade(trade(1, "Q", 100, 100, 0))
+		l.ApplyTrade(trade(2, "D", 101, 200, 1))
+		l.ApplyTrade(trade(3, "Q", 102, 50, 2))
+
+		l.ApplyCorrection(correction(2, 4, "D", trade(2, "D", 101, 200, 1), trade(4, "D", 101, 20, 1, "I")))
+		l.ApplyCancelError(cancel(3, "Q", 50, CancelAction))
+
+		breakdown := l.Venues("aapl")
+
+		// whether rebuilt or amended in place, the odd lot's volume is re-added and the canceled trade subtracted
+		if breakdown.Volume != 120 || breakdown.TradeCount != 2 || breakdown.OffExchangeVolume != 20 {
+			t.Fatalf("capacity %d: unexpected breakdown %+v", capacity, breakdown)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package sales
+
+// ring is a fixed capacity buffer of trades that overwrites the oldest trade when full
+type ring struct {
+	trades []*Trade
+	start  int
+	size   int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{
+		trades: make([]*Trade, capacity),
+	}
+}
+
+func (r *ring) push(trade *Trade) {
+	end := (r.start + r.size) % len(r.trades)
+	r.trades[end] = trade
+
+	if r.size < len(r.trades) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.trades)
+	}
+}
+
+func (r *ring) len() int {
+	return r.size
+}
+
+// at returns the i-th oldest trade
+func (r *ring) at(i int) *Trade {
+	return r.trades[(r.start+i)%len(r.trades)]
+}
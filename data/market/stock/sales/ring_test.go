@@ -0,0 +1,64 @@
+package sales
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingOverwritesOldest(t *testing.T) {
+	r := newRing(3)
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		r.push(&Trade{Seq: seq})
+	}
+
+	if r.len() != 3 {
+		t.Fatalf("expected 3 trades, got %d", r.len())
+	}
+
+	for i, expected := range []uint64{3, 4, 5} {
+		if seq := r.at(i).Seq; seq != expected {
+			t.Fatalf("expected trade %d at %d, got %d", expected, i, seq)
+		}
+	}
+}
+
+func TestTimeAndSalesPages(t *testing.T) {
+	l := NewLedger(5)
+
+	for id := int64(1); id <= 8; id++ {
+		l.ApplyTrade(trade(id, "Q", 100, 100, int(id)))
+	}
+
+	tests := []struct {
+		name           string
+		symbol         string
+		limit          int
+		before         uint64
+		expected       []uint64
+		expectedBefore uint64
+	}{
+		{"latest", "AAPL", 2, 0, []uint64{8, 7}, 7},
+		{"older", "aapl", 2, 7, []uint64{6, 5}, 5},
+		// the ring only holds the latest five trades
+		{"oldest", "AAPL", 2, 5, []uint64{4}, 0},
+		{"everything", "AAPL", 10, 0, []uint64{8, 7, 6, 5, 4}, 0},
+		{"exactly the rest", "AAPL", 2, 6, []uint64{5, 4}, 0},
+		{"dropped cursor", "AAPL", 2, 2, []uint64{}, 0},
+		{"no limit", "AAPL", 0, 0, []uint64{}, 0},
+		{"unknown symbol", "MSFT", 2, 0, []uint64{}, 0},
+	}
+
+	for _, test := range tests {
+		page := l.TimeAndSales(test.symbol, test.limit, test.before)
+		seqs := make([]uint64, len(page.Trades))
+
+		for i, trade := range page.Trades {
+			seqs[i] = trade.Seq
+		}
+
+		if !reflect.DeepEqual(seqs, test.expected) || page.Before != test.expectedBefore || page.Trades == nil {
+			t.Errorf("%s: expected %v before %d, got %v before %d", test.name, test.expected, test.expectedBefore, seqs, page.Before)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/clock"
+	"github.com/phoobynet/buffalo/data/market/feed"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"github.com/phoobynet/buffalo/data/market/stock/ticker"
+	"github.com/phoobynet/buffalo/data/metadata/asset"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BatchSize is the number of symbols requested per snapshots request
+	BatchSize = 500
+	// RegularInterval is the time between refreshes during regular hours
+	RegularInterval = time.Minute
+	// ExtendedInterval is the time between refreshes during the pre-market and post-market
+	ExtendedInterval = 5 * time.Minute
+	// refreshDelay is how long after an interval starts it is refreshed, allowing the latest minute bars to complete
+	refreshDelay = 5 * time.Second
+	// RetryInterval is the time before a refresh that could not list its symbols is retried, doubling after each
+	// consecutive failure up to ExtendedInterval
+	RetryInterval = 15 * time.Second
+	DefaultLimit  = 25
+	MaxLimit      = 500
+)
+
+// Ranking is the order in which scanned symbols are listed
+type Ranking string
+
+const (
+	// Gainers are the symbols up the most since the previous close
+	Gainers Ranking = "gainers"
+	// Losers are the symbols down the most since the previous close
+	Losers Ranking = "losers"
+	// MostActive are the symbols with the highest volume
+	MostActive Ranking = "most_active"
+	// Gappers are the symbols that opened furthest from the previous close, in either direction
+	Gappers Ranking = "gappers"
+)
+
+var (
+	ErrInvalidRanking = errors.New("invalid ranking")
+	ErrNotRefreshed   = errors.New("the scanner has not been refreshed yet")
+)
+
+// Row is a scanned symbol's session statistics
+type Row struct {
+	Symbol        string    `json:"symbol"`
+	Name          string    `json:"name"`
+	Exchange      string    `json:"exchange"`
+	SessionDate   string    `json:"sessionDate"`
+	Last          float64   `json:"last"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	Bid           float64   `json:"bid"`
+	Ask           float64   `json:"ask"`
+	Open          float64   `json:"open"`
+	PreviousClose float64   `json:"previousClose"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"changePercent"`
+	// GapPercent is the open's change from the previous close as a percentage. Before the open, it is the last
+	// price's change, the gap the symbol is indicated to open with.
+	GapPercent   float64 `json:"gapPercent"`
+	Volume       uint64  `json:"volume"`
+	VWAP         float64 `json:"vwap"`
+	DollarVolume float64 `json:"dollarVolume"`
+	asset        *alpaca.Asset
+}
+
+func newRow(t ticker.Ticker, a *alpaca.Asset) Row {
+	row := Row{
+		Symbol:        t.Symbol,
+		SessionDate:   t.SessionDate,
+		Last:          t.Last,
+		LastTimestamp: t.LastTimestamp,
+		Bid:           t.Bid,
+		Ask:           t.Ask,
+		Open:          t.Open,
+		PreviousClose: t.PreviousClose,
+		Change:        t.Change,
+		ChangePercent: t.ChangePercent,
+		Volume:        t.Volume,
+		VWAP:          t.VWAP,
+		DollarVolume:  t.VWAP * float64(t.Volume),
+		asset:         a,
+	}
+
+	if row.DollarVolume == 0 {
+		row.DollarVolume = t.Last * float64(t.Volume)
+	}
+
+	if t.PreviousClose > 0 {
+		open := t.Open
+
+		if open == 0 {
+			open = t.Last
+		}
+
+		row.GapPercent = (open - t.PreviousClose) / t.PreviousClose * 100
+	}
+
+	if a != nil {
+		row.Name = a.Name
+		row.Exchange = a.Exchange
+	}
+
+	return row
+}
+
+// Filter limits the scanned symbols. Zero values are not applied, and the asset attributes are only required when
+// set.
+type Filter struct {
+	MinPrice        float64 `json:"minPrice"`
+	MaxPrice        float64 `json:"maxPrice"`
+	MinVolume       uint64  `json:"minVolume"`
+	MinDollarVolume float64 `json:"minDollarVolume"`
+	// Exchanges are the listing exchanges to include, e.g. NASDAQ, NYSE or ARCA
+	Exchanges    []string `json:"exchanges"`
+	Tradable     bool     `json:"tradable"`
+	Marginable   bool     `json:"marginable"`
+	Shortable    bool     `json:"shortable"`
+	EasyToBorrow bool     `json:"easyToBorrow"`
+	Fractionable bool     `json:"fractionable"`
+	Limit        int      `json:"limit"`
+}
+
+func (f Filter) matches(row Row) bool {
+	if row.Last <= 0 ||
+		(f.MinPrice > 0 && row.Last < f.MinPrice) ||
+		(f.MaxPrice > 0 && row.Last > f.MaxPrice) ||
+		row.Volume < f.MinVolume ||
+		row.DollarVolume < f.MinDollarVolume {
+		return false
+	}
+
+	if len(f.Exchanges) > 0 {
+		found := false
+
+		for _, exchange := range f.Exchanges {
+			found = found || strings.EqualFold(exchange, row.Exchange)
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	a := row.asset
+
+	if f.Tradable || f.Marginable || f.Shortable || f.EasyToBorrow || f.Fractionable {
+		if a == nil ||
+			(f.Tradable && !a.Tradable) ||
+			(f.Marginable && !a.Marginable) ||
+			(f.Shortable && !a.Shortable) ||
+			(f.EasyToBorrow && !a.EasyToBorrow) ||
+			(f.Fractionable && !a.Fractionable) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Result is a ranking of the latest refresh. Matches is the number of symbols that passed the filter, before the
+// limit was applied.
+type Result struct {
+	Ranking     Ranking   `json:"ranking"`
+	RefreshedAt time.Time `json:"refreshedAt"`
+	Matches     int       `json:"matches"`
+	Rows        []Row     `json:"rows"`
+}
+
+// Refreshed describes a completed refresh
+type Refreshed struct {
+	RefreshedAt time.Time `json:"refreshedAt"`
+	Symbols     int       `json:"symbols"`
+	// Failed is the number of symbols in batches that could not be requested
+	Failed int    `json:"failed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Scanner ranks the snapshots of the active US equities, or a chosen universe of symbols, refreshing them in batches
+// on a schedule that follows the market clock: every minute during regular hours, every five minutes during the
+// pre-market and post-market, and once after the session closes. The schedule starts when the scanner is first used.
+type Scanner struct {
+	mut              sync.RWMutex
+	marketDataClient source.HistoricalSource
+	feedSelector     *feed.Selector
+	assetRepository  *asset.Repository
+	refreshed        chan Refreshed
+	universe         []string
+	// generation counts the changes to the universe, so that a refresh of a previous universe is not mistaken for
+	// a refresh of the current one
+	generation  int
+	rows        []Row
+	refreshedAt time.Time
+	refreshing  bool
+	active      bool
+	failures    int
+	retryAt     time.Time
+}
+
+func NewScanner(marketDataClient source.HistoricalSource, feedSelector *feed.Selector, assetRepository *asset.Repository, refreshed chan Refreshed) *Scanner {
+	return &Scanner{
+		marketDataClient: marketDataClient,
+		feedSelector:     feedSelector,
+		assetRepository:  assetRepository,
+		refreshed:        refreshed,
+	}
+}
+
+// SetUniverse restricts the scanner to the symbols, or every active US equity if there are none, and refreshes it
+// at the next status
+func (s *Scanner) SetUniverse(symbols []string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.universe = make([]string, 0, len(symbols))
+
+	for _, symbol := range symbols {
+		if symbol = strings.TrimSpace(strings.ToUpper(symbol)); symbol != "" {
+			s.universe = append(s.universe, symbol)
+		}
+	}
+
+	s.generation++
+	s.refreshedAt = time.Time{}
+	s.failures = 0
+	s.retryAt = time.Time{}
+	s.active = true
+}
+
+// Universe returns the chosen symbols, empty if every active US equity is scanned
+func (s *Scanner) Universe() []string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return append([]string{}, s.universe...)
+}
+
+// due returns true if the scanner should be refreshed at the status' time
+func (s *Scanner) due(status clock.Status) bool {
+	if status.CurrentTime.Before(s.retryAt) {
+		return false
+	}
+
+	if s.refreshedAt.IsZero() {
+		return true
+	}
+
+	day := status.Calendar
+	now := status.CurrentTime
+
+	if day == nil {
+		return false
+	}
+
+	var interval time.Duration
+
+	switch {
+	case !now.Before(day.Open) && now.Before(day.Close):
+		interval = RegularInterval
+	case !now.Before(day.SessionOpen) && now.Before(day.SessionClose):
+		interval = ExtendedInterval
+	default:
+		// once after the session closes, for the final figures
+		return !now.Before(day.SessionClose.Add(refreshDelay)) && s.refreshedAt.Before(day.SessionClose)
+	}
+
+	boundary := now.Truncate(interval).Add(refreshDelay)
+
+	if now.Before(boundary) {
+		boundary = boundary.Add(-interval)
+	}
+
+	return s.refreshedAt.Before(boundary)
+}
+
+// ApplyStatus refreshes the scanner in the background when it is due at the status' time
+func (s *Scanner) ApplyStatus(status clock.Status) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.active || s.refreshing || !s.due(status) {
+		return
+	}
+
+	s.refreshing = true
+
+	go func() {
+		refreshed := s.Refresh()
+
+		if s.refreshed != nil {
+			s.refreshed <- refreshed
+		}
+	}()
+}
+
+// symbols returns the symbols to scan and the assets by symbol
+func (s *Scanner) symbols() ([]string, map[string]*alpaca.Asset, error) {
+	assets, err := s.assetRepository.GetActive(alpaca.USEquity)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bySymbol := make(map[string]*alpaca.Asset, len(assets))
+
+	for i := range assets {
+		bySymbol[assets[i].Symbol] = &assets[i]
+	}
+
+	universe := s.Universe()
+
+	if len(universe) > 0 {
+		return universe, bySymbol, nil
+	}
+
+	symbols := make([]string, len(assets))
+
+	for i, a := range assets {
+		symbols[i] = a.Symbol
+	}
+
+	return symbols, bySymbol, nil
+}
+
+// getSnapshots requests snapshots from the selected feed, falling back to IEX if SIP is rejected
+func (s *Scanner) getSnapshots(symbols []string) (map[string]*marketdata.Snapshot, error) {
+	var snapshots map[string]*marketdata.Snapshot
+
+	err := s.feedSelector.Do(func(f marketdata.Feed) error {
+		var err error
+		snapshots, err = s.marketDataClient.GetSnapshots(symbols, marketdata.GetSnapshotRequest{Feed: f})
+
+		return err
+	})
+
+	return snapshots, err
+}
+
+// Refresh requests the snapshots of every symbol in batches, replacing the scanned rows. Batches that fail are
+// skipped, leaving their symbols out until the next refresh.
+func (s *Scanner) Refresh() Refreshed {
+	started := time.Now()
+
+	defer func() {
+		s.mut.Lock()
+		s.refreshing = false
+		s.mut.Unlock()
+	}()
+
+	refreshed := Refreshed{RefreshedAt: started}
+
+	s.mut.RLock()
+	generation := s.generation
+	s.mut.RUnlock()
+
+	symbols, assets, err := s.symbols()
+
+	if err != nil {
+		s.backOff(started)
+		refreshed.Error = err.Error()
+		return refreshed
+	}
+
+	rows := make([]Row, 0, len(symbols))
+
+	for i := 0; i < len(symbols); i += BatchSize {
+		end := i + BatchSize
+
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+
+		batch := symbols[i:end]
+		snapshots, err := s.getSnapshots(batch)
+
+		if err != nil {
+			log.Printf("failed to scan %d symbols from %s: %v", len(batch), batch[0], err)
+			refreshed.Failed += len(batch)
+			refreshed.Error = err.Error()
+			continue
+		}
+
+		for symbol, snapshot := range snapshots {
+			if snapshot == nil || snapshot.LatestTrade == nil {
+				continue
+			}
+
+			rows = append(rows, newRow(ticker.FromSnapshot(symbol, snapshot), assets[symbol]))
+		}
+	}
+
+	refreshed.Symbols = len(rows)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	// a refresh where every batch failed keeps the previous rows
+	if refreshed.Failed == 0 || len(rows) > 0 {
+		s.rows = rows
+	}
+
+	if generation == s.generation {
+		s.refreshedAt = started
+		s.failures = 0
+		s.retryAt = time.Time{}
+	}
+
+	return refreshed
+}
+
+// backOff delays the next refresh after a failed one, doubling the delay with each consecutive failure
+func (s *Scanner) backOff(failedAt time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.failures++
+	delay := RetryInterval
+
+	for i := 1; i < s.failures && delay < ExtendedInterval; i++ {
+		delay *= 2
+	}
+
+	if delay > ExtendedInterval {
+		delay = ExtendedInterval
+	}
+
+	s.retryAt = failedAt.Add(delay)
+}
+
+// Scan returns the rows of the latest refresh that pass the filter, in the ranking's order. ErrNotRefreshed is
+// returned until the first refresh started by the scan completes.
+func (s *Scanner) Scan(ranking Ranking, filter Filter) (*Result, error) {
+	var less func(a, b Row) bool
+	var include func(row Row) bool
+
+	switch ranking {
+	case Gainers:
+		include = func(row Row) bool { return row.PreviousClose > 0 && row.ChangePercent > 0 }
+		less = func(a, b Row) bool { return a.ChangePercent > b.ChangePercent }
+	case Losers:
+		include = func(row Row) bool { return row.PreviousClose > 0 && row.ChangePercent < 0 }
+		less = func(a, b Row) bool { return a.ChangePercent < b.ChangePercent }
+	case MostActive:
+		include = func(row Row) bool { return row.Volume > 0 }
+		less = func(a, b Row) bool { return a.Volume > b.Volume }
+	case Gappers:
+		include = func(row Row) bool { return row.PreviousClose > 0 && row.GapPercent != 0 }
+		less = func(a, b Row) bool { return math.Abs(a.GapPercent) > math.Abs(b.GapPercent) }
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidRanking, ranking)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.active = true
+
+	if s.refreshedAt.IsZero() && len(s.rows) == 0 {
+		return nil, ErrNotRefreshed
+	}
+
+	rows := make([]Row, 0)
+
+	for _, row := range s.rows {
+		if include(row) && filter.matches(row) {
+			rows = append(rows, row)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if less(rows[i], rows[j]) {
+			return true
+		}
+
+		if less(rows[j], rows[i]) {
+			return false
+		}
+
+		return rows[i].Symbol < rows[j].Symbol
+	})
+
+	limit := filter.Limit
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	result := &Result{
+		Ranking:     ranking,
+		RefreshedAt: s.refreshedAt,
+		Matches:     len(rows),
+		Rows:        rows,
+	}
+
+	if len(rows) > limit {
+		result.Rows = rows[:limit]
+	}
+
+	return result, nil
+}
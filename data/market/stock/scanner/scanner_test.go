@@ -0,0 +1,330 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/clock"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"github.com/phoobynet/buffalo/data/testutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// batchingMarket records the size of each snapshots request and fails the batches numbered in failing
+type batchingMarket struct {
+	*fake.Market
+	batches []int
+	failing map[int]bool
+}
+
+func (m *batchingMarket) GetSnapshots(symbols []string, req marketdata.GetSnapshotRequest) (map[string]*marketdata.Snapshot, error) {
+	m.batches = append(m.batches, len(symbols))
+
+	if m.failing[len(m.batches)] {
+		return nil, errors.New("batch rejected")
+	}
+
+	return m.Market.GetSnapshots(symbols, req)
+}
+
+// newFakeScanner returns a scanner over the fake market on a Friday morning, with its assets
+func newFakeScanner(t *testing.T, failing ...int) (*Scanner, *batchingMarket) {
+	t.Helper()
+
+	now := time.Date(2026, 10, 16, 11, 0, 0, 0, newYork)
+	market := &batchingMarket{Market: fake.NewMarket(fake.Options{Now: func() time.Time { return now }}), failing: make(map[int]bool)}
+
+	for _, batch := range failing {
+		market.failing[batch] = true
+	}
+
+	db := testutil.DB(t)
+
+	return NewScanner(market, testutil.FeedSelector(), testutil.AssetRepository(t, db, market.Market), nil), market
+}
+
+func TestRefreshRequestsSnapshotsInBatches(t *testing.T) {
+	s, market := newFakeScanner(t, 2)
+	universe := make([]string, 2*BatchSize+200)
+
+	for i := range universe {
+		universe[i] = fmt.Sprintf("S%04d", i)
+	}
+
+	s.SetUniverse(universe)
+	refreshed := s.Refresh()
+
+	if !reflect.DeepEqual(market.batches, []int{BatchSize, BatchSize, 200}) {
+		t.Fatalf("unexpected batches %v", market.batches)
+	}
+
+	// the failed batch is left out until the next refresh
+	if refreshed.Failed != BatchSize || refreshed.Symbols != BatchSize+200 || refreshed.Error == "" {
+		t.Fatalf("unexpected refresh %+v", refreshed)
+	}
+
+	result, err := s.Scan(MostActive, Filter{Limit: MaxLimit})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Matches != BatchSize+200 {
+		t.Fatalf("expected %d matches, got %d", BatchSize+200, result.Matches)
+	}
+}
+
+func TestRefreshScansListedAssets(t *testing.T) {
+	s, _ := newFakeScanner(t)
+
+	if _, err := s.Scan(Gainers, Filter{}); !errors.Is(err, ErrNotRefreshed) {
+		t.Fatalf("expected ErrNotRefreshed before the first refresh, got %v", err)
+	}
+
+	refreshed := s.Refresh()
+
+	if refreshed.Error != "" || refreshed.Symbols == 0 {
+		t.Fatalf("unexpected refresh %+v", refreshed)
+	}
+
+	result, err := s.Scan(MostActive, Filter{Exchanges: []string{"nyse"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Rows) == 0 {
+		t.Fatal("expected NYSE listings")
+	}
+
+	for i, row := range result.Rows {
+		if row.Exchange != "NYSE" || row.Name == "" || row.Volume == 0 {
+			t.Fatalf("unexpected row %+v", row)
+		}
+
+		if i > 0 && row.Volume > result.Rows[i-1].Volume {
+			t.Fatalf("rows are not ordered by volume: %d after %d", row.Volume, result.Rows[i-1].Volume)
+		}
+	}
+}
+
+// scannerWithRows returns a scanner refreshed with the rows
+func scannerWithRows(rows ...Row) *Scanner {
+	return &Scanner{rows: rows, refreshedAt: time.Now()}
+}
+
+func symbolsOf(result *Result) []string {
+	symbols := make([]string, len(result.Rows))
+
+	for i, row := range result.Rows {
+		symbols[i] = row.Symbol
+	}
+
+	return symbols
+}
+
+func TestScanRankings(t *testing.T) {
+	s := scannerWithRows(
+		Row{Symbol: "UP", Last: 11, PreviousClose: 10, ChangePercent: 10, GapPercent: 2, Volume: 100},
+		Row{Symbol: "UPTIE", Last: 22, PreviousClose: 20, ChangePercent: 10, GapPercent: -5, Volume: 300},
+		Row{Symbol: "ABTIE", Last: 33, PreviousClose: 30, ChangePercent: 10, GapPercent: 5, Volume: 300},
+		Row{Symbol: "DOWN", Last: 9, PreviousClose: 10, ChangePercent: -10, GapPercent: -1, Volume: 200},
+		Row{Symbol: "FLAT", Last: 10, PreviousClose: 10, Volume: 50},
+		Row{Symbol: "NEW", Last: 10, Volume: 400},
+	)
+
+	tests := []struct {
+		ranking  Ranking
+		expected []string
+	}{
+		// ties are ordered by symbol
+		{Gainers, []string{"ABTIE", "UP", "UPTIE"}},
+		{Losers, []string{"DOWN"}},
+		{MostActive, []string{"NEW", "ABTIE", "UPTIE", "DOWN", "UP", "FLAT"}},
+		{Gappers, []string{"ABTIE", "UPTIE", "UP", "DOWN"}},
+	}
+
+	for _, test := range tests {
+		result, err := s.Scan(test.ranking, Filter{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := symbolsOf(result); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.ranking, test.expected, actual)
+		}
+	}
+
+	if _, err := s.Scan("biggest", Filter{}); !errors.Is(err, ErrInvalidRanking) {
+		t.Fatalf("expected ErrInvalidRanking, got %v", err)
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	shortable := &alpaca.Asset{Tradable: true, Shortable: true, EasyToBorrow: true}
+	hardToBorrow := &alpaca.Asset{Tradable: true, Shortable: true}
+
+	s := scannerWithRows(
+		Row{Symbol: "CHEAP", Exchange: "NASDAQ", Last: 2, Volume: 1_000_000, DollarVolume: 2_000_000, asset: shortable},
+		Row{Symbol: "MID", Exchange: "NYSE", Last: 50, Volume: 100_000, DollarVolume: 5_000_000, asset: hardToBorrow},
+		Row{Symbol: "RICH", Exchange: "ARCA", Last: 500, Volume: 10_000, DollarVolume: 5_000_000, asset: shortable},
+		Row{Symbol: "NOASSET", Exchange: "NYSE", Last: 40, Volume: 50_000, DollarVolume: 2_000_000},
+		Row{Symbol: "UNTRADED", Exchange: "NYSE", Volume: 0},
+	)
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"none", Filter{}, []string{"CHEAP", "MID", "NOASSET", "RICH"}},
+		{"min price", Filter{MinPrice: 40}, []string{"MID", "NOASSET", "RICH"}},
+		{"max price", Filter{MaxPrice: 50}, []string{"CHEAP", "MID", "NOASSET"}},
+		{"min volume", Filter{MinVolume: 50_000}, []string{"CHEAP", "MID", "NOASSET"}},
+		{"min dollar volume", Filter{MinDollarVolume: 5_000_000}, []string{"MID", "RICH"}},
+		{"exchanges", Filter{Exchanges: []string{"nyse", "ARCA"}}, []string{"MID", "NOASSET", "RICH"}},
+		{"asset attributes", Filter{Shortable: true}, []string{"CHEAP", "MID", "RICH"}},
+		{"easy to borrow", Filter{EasyToBorrow: true}, []string{"CHEAP", "RICH"}},
+	}
+
+	for _, test := range tests {
+		result, err := s.Scan(MostActive, test.filter)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := symbolsOf(result)
+		expected := make(map[string]bool, len(test.expected))
+
+		for _, symbol := range test.expected {
+			expected[symbol] = true
+		}
+
+		if len(actual) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+			continue
+		}
+
+		for _, symbol := range actual {
+			if !expected[symbol] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestScanLimit(t *testing.T) {
+	rows := make([]Row, MaxLimit+10)
+
+	for i := range rows {
+		rows[i] = Row{Symbol: fmt.Sprintf("S%04d", i), Last: 10, Volume: uint64(i + 1)}
+	}
+
+	s := scannerWithRows(rows...)
+
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, DefaultLimit},
+		{-1, DefaultLimit},
+		{10, 10},
+		{MaxLimit + 5, MaxLimit},
+	}
+
+	for _, test := range tests {
+		result, err := s.Scan(MostActive, Filter{Limit: test.limit})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Rows) != test.expected || result.Matches != len(rows) {
+			t.Errorf("limit %d: expected %d of %d rows, got %d of %d", test.limit, test.expected, len(rows), len(result.Rows), result.Matches)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2026, 10, 16, hour, minute, second, 0, newYork)
+	}
+
+	day := &calendar.Calendar{
+		Date:         "2026-10-16",
+		Open:         at(9, 30, 0),
+		Close:        at(16, 0, 0),
+		SessionOpen:  at(4, 0, 0),
+		SessionClose: at(20, 0, 0),
+	}
+
+	tests := []struct {
+		name        string
+		refreshedAt time.Time
+		now         time.Time
+		calendar    *calendar.Calendar
+		expected    bool
+	}{
+		{"never refreshed", time.Time{}, at(2, 0, 0), nil, true},
+		{"no trading day", at(10, 0, 5), at(11, 0, 0), nil, false},
+		{"regular within the minute", at(10, 0, 5), at(10, 0, 59), day, false},
+		{"regular before the delay", at(10, 0, 5), at(10, 1, 4), day, false},
+		{"regular next minute", at(10, 0, 5), at(10, 1, 5), day, true},
+		{"pre-market within the interval", at(7, 0, 5), at(7, 4, 59), day, false},
+		{"pre-market next interval", at(7, 0, 5), at(7, 5, 5), day, true},
+		{"post-market next interval", at(16, 5, 5), at(16, 10, 5), day, true},
+		{"after the close before the delay", at(19, 55, 5), at(20, 0, 4), day, false},
+		{"after the close", at(19, 55, 5), at(20, 0, 5), day, true},
+		{"after the close once", at(20, 0, 5), at(23, 0, 0), day, false},
+		{"before the session", at(20, 0, 5), at(3, 0, 0), day, false},
+	}
+
+	for _, test := range tests {
+		s := &Scanner{refreshedAt: test.refreshedAt}
+
+		if actual := s.due(clock.Status{CurrentTime: test.now, Calendar: test.calendar}); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestRefreshBacksOffWhenSymbolsFail(t *testing.T) {
+	db := testutil.DB(t)
+	market := fake.NewMarket(fake.Options{})
+	s := NewScanner(market, testutil.FeedSelector(), testutil.AssetRepository(t, db, market), nil)
+	s.SetUniverse(nil)
+
+	// the asset table can no longer be read
+	testutil.CloseDB(t, db)
+
+	refreshed := s.Refresh()
+
+	if refreshed.Error == "" {
+		t.Fatal("expected the refresh to fail")
+	}
+
+	now := time.Now()
+
+	if s.due(clock.Status{CurrentTime: now}) {
+		t.Fatal("expected the failed refresh to back off")
+	}
+
+	if !s.due(clock.Status{CurrentTime: now.Add(RetryInterval)}) {
+		t.Fatal("expected a retry once the backoff elapses")
+	}
+
+	s.Refresh()
+
+	if s.due(clock.Status{CurrentTime: now.Add(RetryInterval)}) {
+		t.Fatal("expected the backoff to double after consecutive failures")
+	}
+}
@@ -0,0 +1,46 @@
+package stock
+
+import (
+	"errors"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"time"
+)
+
+// ConnectionState describes the state of the stream's upstream connection
+type ConnectionState string
+
+const (
+	Connecting               ConnectionState = "connecting"
+	Connected                ConnectionState = "connected"
+	Reconnecting             ConnectionState = "reconnecting"
+	Failed                   ConnectionState = "failed"
+	AuthError                ConnectionState = "auth-error"
+	InsufficientSubscription ConnectionState = "insufficient-subscription"
+)
+
+// Status is a change in the stream's connection state
+type Status struct {
+	State   ConnectionState `json:"state"`
+	Attempt int             `json:"attempt"`
+	Error   string          `json:"error,omitempty"`
+	Time    time.Time       `json:"time"`
+}
+
+// IsTerminal returns true if the stream has given up reconnecting
+func (s Status) IsTerminal() bool {
+	return s.State == Failed || s.State == AuthError || s.State == InsufficientSubscription
+}
+
+// irrecoverableState returns the terminal state for errors that retrying will not fix, or "" otherwise
+func irrecoverableState(err error) ConnectionState {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, stream.ErrInvalidCredentials), errors.Is(err, stream.ErrInsufficientScope):
+		return AuthError
+	case errors.Is(err, stream.ErrInsufficientSubscription):
+		return InsufficientSubscription
+	default:
+		return ""
+	}
+}
@@ -2,57 +2,450 @@ package stock
 
 import (
 	"context"
-	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"fmt"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"github.com/phoobynet/buffalo/data/market/tape"
 	"sync"
+	"time"
+)
+
+const (
+	reconnectMaxAttempts = 10
+	reconnectBaseDelay   = time.Second
+	reconnectMaxDelay    = 30 * time.Second
 )
 
 type Stream struct {
-	mut          sync.Mutex
-	trades       chan stream.Trade
-	quotes       chan stream.Quote
-	bars         chan stream.Bar
-	stocksClient *stream.StocksClient
+	mut           sync.Mutex
+	ctx           context.Context
+	newSource     source.StreamFactory
+	trades        *outlet[stream.Trade]
+	quotes        *outlet[stream.Quote]
+	bars          *outlet[stream.Bar]
+	updatedBars   *outlet[stream.Bar]
+	dailyBars     *outlet[stream.Bar]
+	tradingStatus *outlet[stream.TradingStatus]
+	lulds         *outlet[stream.LULD]
+	corrections   *outlet[stream.TradeCorrection]
+	cancelErrors  *outlet[stream.TradeCancelError]
+	statuses      chan Status
+	stocksClient  source.StreamSource
+	running       bool
+	restart       bool
+	cancelConn    context.CancelFunc
+	restarted     chan struct{}
+	status        Status
+	subscriptions *subscriptions
+	recorderMut   sync.RWMutex
+	recorder      *tape.Recorder
+}
+
+// Channels receive the messages forwarded by a Stream. Everything other than trades, quotes and minute bars is
+// only subscribed to when its channel is set.
+type Channels struct {
+	Trades        chan stream.Trade
+	Quotes        chan stream.Quote
+	Bars          chan stream.Bar
+	UpdatedBars   chan stream.Bar
+	DailyBars     chan stream.Bar
+	TradingStatus chan stream.TradingStatus
+	LULDs         chan stream.LULD
+	Corrections   chan stream.TradeCorrection
+	CancelErrors  chan stream.TradeCancelError
+	// Statuses receives connection state changes
+	Statuses chan Status
+}
+
+// NewStream connects to a stream source created by newSource in the background, forwarding received messages to
+// the given channels. Lost connections are re-established with backoff, restoring every active subscription.
+func NewStream(ctx context.Context, newSource source.StreamFactory, channels Channels) (*Stream, error) {
+	s := &Stream{
+		ctx:           ctx,
+		newSource:     newSource,
+		trades:        newOutlet(ctx, "trades", channels.Trades, Block, func(t stream.Trade) string { return t.Symbol }),
+		quotes:        newOutlet(ctx, "quotes", channels.Quotes, ConflatePerSymbol, func(q stream.Quote) string { return q.Symbol }),
+		bars:          newOutlet(ctx, "bars", channels.Bars, Block, func(b stream.Bar) string { return b.Symbol }),
+		statuses:      channels.Statuses,
+		restarted:     make(chan struct{}, 1),
+		subscriptions: newSubscriptions(),
+	}
+
+	if channels.UpdatedBars != nil {
+		s.updatedBars = newOutlet(ctx, "updated-bars", channels.UpdatedBars, Block, func(b stream.Bar) string { return b.Symbol })
+	}
+
+	if channels.DailyBars != nil {
+		s.dailyBars = newOutlet(ctx, "daily-bars", channels.DailyBars, ConflatePerSymbol, func(b stream.Bar) string { return b.Symbol })
+	}
+
+	if channels.TradingStatus != nil {
+		s.tradingStatus = newOutlet(ctx, "trading-status", channels.TradingStatus, Block, func(t stream.TradingStatus) string { return t.Symbol })
+	}
+
+	if channels.LULDs != nil {
+		s.lulds = newOutlet(ctx, "lulds", channels.LULDs, ConflatePerSymbol, func(l stream.LULD) string { return l.Symbol })
+	}
+
+	if channels.Corrections != nil {
+		s.corrections = newOutlet(ctx, "corrections", channels.Corrections, Block, func(c stream.TradeCorrection) string { return c.Symbol })
+	}
+
+	if channels.CancelErrors != nil {
+		s.cancelErrors = newOutlet(ctx, "cancel-errors", channels.CancelErrors, Block, func(c stream.TradeCancelError) string { return c.Symbol })
+	}
+
+	s.Reconnect()
+
+	return s, nil
+}
+
+// Reconnect restarts the connection loop if it has given up, e.g. after a failure or an authentication error
+func (s *Stream) Reconnect() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.running {
+		return
+	}
+
+	s.running = true
+
+	go s.run()
+}
+
+// Restart drops the current connection, if any, and connects again with a new source from the factory,
+// e.g. after the feed has changed
+func (s *Stream) Restart() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.running {
+		s.running = true
+
+		go s.run()
+
+		return
+	}
+
+	s.restart = true
+
+	if s.cancelConn != nil {
+		s.cancelConn()
+	}
+
+	select {
+	case s.restarted <- struct{}{}:
+	default:
+	}
 }
 
-func NewStream(ctx context.Context, trades chan stream.Trade, quotes chan stream.Quote, bars chan stream.Bar) (*Stream, error) {
-	stocksClient := stream.NewStocksClient(marketdata.SIP)
+// Status returns the current connection status
+func (s *Stream) Status() Status {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.status
+}
+
+func (s *Stream) run() {
+	attempt := 0
+
+	for {
+		if attempt == 0 {
+			s.setStatus(Connecting, attempt, nil)
+		}
+
+		connCtx, cancelConn := context.WithCancel(s.ctx)
+
+		s.mut.Lock()
+		s.cancelConn = cancelConn
+		s.restart = false
+		s.mut.Unlock()
+
+		select {
+		case <-s.restarted:
+		default:
+		}
+
+		stocksClient := s.newSource(s.onConnect, s.onDisconnect)
+
+		err := stocksClient.Connect(connCtx)
+
+		if err == nil {
+			attempt = 0
+			err = s.attach(stocksClient)
+
+			if err == nil {
+				s.setStatus(Connected, attempt, nil)
+				err = <-stocksClient.Terminated()
+			}
+
+			s.detach(stocksClient)
+		}
 
-	err := stocksClient.Connect(ctx)
+		cancelConn()
+
+		if s.ctx.Err() != nil {
+			s.stop()
+			return
+		}
+
+		if s.isRestarting() {
+			attempt = 0
+			continue
+		}
+
+		if state := irrecoverableState(err); state != "" {
+			s.stop()
+			s.setStatus(state, attempt, err)
+			return
+		}
+
+		attempt++
+
+		if attempt > reconnectMaxAttempts {
+			s.stop()
+			s.setStatus(Failed, attempt, err)
+			return
+		}
+
+		s.setStatus(Reconnecting, attempt, err)
+
+		select {
+		case <-time.After(reconnectDelay(attempt)):
+		case <-s.restarted:
+			attempt = 0
+		case <-s.ctx.Done():
+			s.stop()
+			return
+		}
+	}
+}
+
+// stop marks the connection loop as stopped before its final status is reported, so that the status'
+// receiver can Reconnect straight away
+func (s *Stream) stop() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.running = false
+	s.cancelConn = nil
+}
+
+func (s *Stream) isRestarting() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.restart
+}
+
+// reconnectDelay doubles the delay for every consecutive failed attempt
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectBaseDelay
+
+	for i := 1; i < attempt && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > reconnectMaxDelay {
+		return reconnectMaxDelay
+	}
+
+	return delay
+}
+
+// attach makes the connected client current and restores every active subscription
+func (s *Stream) attach(stocksClient source.StreamSource) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.stocksClient = stocksClient
+
+	if s.corrections != nil {
+		s.stocksClient.RegisterCorrections(s.corrections.send)
+	}
+
+	if s.cancelErrors != nil {
+		s.stocksClient.RegisterCancelErrors(s.cancelErrors.send)
+	}
+
+	return s.subscribe(s.subscriptions.symbols()...)
+}
+
+func (s *Stream) detach(stocksClient source.StreamSource) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.stocksClient == stocksClient {
+		s.stocksClient = nil
+	}
+}
+
+// onConnect and onDisconnect report connections re-established or lost by the source itself. The initial
+// connection is reported by run once subscriptions have been restored.
+func (s *Stream) onConnect() {
+	s.mut.Lock()
+	changed := s.stocksClient != nil && s.status.State != Connected
+	s.mut.Unlock()
+
+	if changed {
+		s.setStatus(Connected, 0, nil)
+	}
+}
+
+func (s *Stream) onDisconnect() {
+	s.mut.Lock()
+	changed := s.status.State == Connected
+	s.mut.Unlock()
+
+	if changed && s.ctx.Err() == nil {
+		s.setStatus(Reconnecting, 0, nil)
+	}
+}
+
+func (s *Stream) setStatus(state ConnectionState, attempt int, err error) {
+	status := Status{
+		State:   state,
+		Attempt: attempt,
+		Time:    time.Now(),
+	}
 
 	if err != nil {
-		return nil, err
+		status.Error = err.Error()
 	}
 
-	return &Stream{stocksClient: stocksClient, trades: trades, quotes: quotes, bars: bars}, nil
+	s.mut.Lock()
+	s.status = status
+	s.mut.Unlock()
+
+	if s.statuses == nil {
+		return
+	}
+
+	select {
+	case s.statuses <- status:
+	case <-s.ctx.Done():
+	}
+}
+
+// Subscribe registers the consumer's interest in the symbols, subscribing upstream to any symbol not already streamed
+func (s *Stream) Subscribe(consumer string, symbols ...string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	added := s.subscriptions.add(consumer, symbols...)
+
+	if err := s.subscribe(added...); err != nil {
+		s.subscriptions.remove(consumer, added...)
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe releases the consumer's interest in the symbols, unsubscribing upstream from any symbol no longer wanted
+func (s *Stream) Unsubscribe(consumer string, symbols ...string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.unsubscribe(s.subscriptions.remove(consumer, symbols...)...)
 }
 
-func (s *Stream) SubscribeTo(symbols ...string) error {
+// SetSymbols replaces the consumer's symbols, applying only the difference upstream. The consumer keeps its previous
+// symbols if the upstream change fails.
+func (s *Stream) SetSymbols(consumer string, symbols ...string) error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	err := s.stocksClient.SubscribeToTrades(func(trade stream.Trade) {
-		s.trades <- trade
-	}, symbols...)
+	previous := s.subscriptions.of(consumer)
+	added, removed := s.subscriptions.replace(consumer, symbols...)
+
+	if err := s.subscribe(added...); err != nil {
+		s.subscriptions.replace(consumer, previous...)
+		return err
+	}
+
+	if err := s.unsubscribe(removed...); err != nil {
+		_, released := s.subscriptions.replace(consumer, previous...)
+		_ = s.unsubscribe(released...)
+		return err
+	}
+
+	return nil
+}
+
+// Subscriptions returns the currently streamed symbols along with their consumers
+func (s *Stream) Subscriptions() []Subscription {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.subscriptions.list()
+}
+
+func (s *Stream) subscribe(symbols ...string) error {
+	if len(symbols) == 0 || s.stocksClient == nil {
+		return nil
+	}
+
+	err := s.stocksClient.SubscribeToTrades(s.onTrade, symbols...)
+
+	if err != nil {
+		return err
+	}
+
+	err = s.stocksClient.SubscribeToQuotes(s.onQuote, symbols...)
 
 	if err != nil {
 		return err
 	}
 
-	err = s.stocksClient.SubscribeToQuotes(func(quote stream.Quote) {
-		s.quotes <- quote
-	}, symbols...)
+	err = s.stocksClient.SubscribeToBars(s.onBar, symbols...)
 
 	if err != nil {
 		return err
 	}
 
-	return s.stocksClient.SubscribeToBars(func(bar stream.Bar) {
-		s.bars <- bar
-	}, symbols...)
+	if s.updatedBars != nil {
+		err = s.stocksClient.SubscribeToUpdatedBars(s.updatedBars.send, symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.dailyBars != nil {
+		err = s.stocksClient.SubscribeToDailyBars(s.dailyBars.send, symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.tradingStatus != nil {
+		err = s.stocksClient.SubscribeToStatuses(s.tradingStatus.send, symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.lulds != nil {
+		err = s.stocksClient.SubscribeToLULDs(s.lulds.send, symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (s *Stream) UnsubscribeFrom(symbols ...string) error {
+func (s *Stream) unsubscribe(symbols ...string) error {
+	if len(symbols) == 0 || s.stocksClient == nil {
+		return nil
+	}
+
 	err := s.stocksClient.UnsubscribeFromTrades(symbols...)
 
 	if err != nil {
@@ -65,5 +458,152 @@ func (s *Stream) UnsubscribeFrom(symbols ...string) error {
 		return err
 	}
 
-	return s.stocksClient.UnsubscribeFromBars(symbols...)
+	err = s.stocksClient.UnsubscribeFromBars(symbols...)
+
+	if err != nil {
+		return err
+	}
+
+	if s.updatedBars != nil {
+		err = s.stocksClient.UnsubscribeFromUpdatedBars(symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.dailyBars != nil {
+		err = s.stocksClient.UnsubscribeFromDailyBars(symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.tradingStatus != nil {
+		err = s.stocksClient.UnsubscribeFromStatuses(symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.lulds != nil {
+		err = s.stocksClient.UnsubscribeFromLULDs(symbols...)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRecorder records every message received to the given recorder, or stops recording if nil
+func (s *Stream) SetRecorder(recorder *tape.Recorder) {
+	s.recorderMut.Lock()
+	defer s.recorderMut.Unlock()
+
+	s.recorder = recorder
+}
+
+func (s *Stream) currentRecorder() *tape.Recorder {
+	s.recorderMut.RLock()
+	defer s.recorderMut.RUnlock()
+
+	return s.recorder
+}
+
+func (s *Stream) onTrade(trade stream.Trade) {
+	if recorder := s.currentRecorder(); recorder != nil {
+		recorder.RecordTrade(trade)
+	}
+
+	s.trades.send(trade)
+}
+
+func (s *Stream) onQuote(quote stream.Quote) {
+	if recorder := s.currentRecorder(); recorder != nil {
+		recorder.RecordQuote(quote)
+	}
+
+	s.quotes.send(quote)
+}
+
+func (s *Stream) onBar(bar stream.Bar) {
+	if recorder := s.currentRecorder(); recorder != nil {
+		recorder.RecordBar(bar)
+	}
+
+	s.bars.send(bar)
+}
+
+// SetPolicy changes the backpressure policy of the named channel, one of "trades", "quotes", "bars",
+// "updated-bars", "daily-bars", "trading-status", "lulds", "corrections" or "cancel-errors"
+func (s *Stream) SetPolicy(channel string, policy Policy) error {
+	switch {
+	case channel == "trades":
+		s.trades.setPolicy(policy)
+	case channel == "quotes":
+		s.quotes.setPolicy(policy)
+	case channel == "bars":
+		s.bars.setPolicy(policy)
+	case channel == "updated-bars" && s.updatedBars != nil:
+		s.updatedBars.setPolicy(policy)
+	case channel == "daily-bars" && s.dailyBars != nil:
+		s.dailyBars.setPolicy(policy)
+	case channel == "trading-status" && s.tradingStatus != nil:
+		s.tradingStatus.setPolicy(policy)
+	case channel == "lulds" && s.lulds != nil:
+		s.lulds.setPolicy(policy)
+	case channel == "corrections" && s.corrections != nil:
+		s.corrections.setPolicy(policy)
+	case channel == "cancel-errors" && s.cancelErrors != nil:
+		s.cancelErrors.setPolicy(policy)
+	default:
+		return fmt.Errorf("unknown stream channel: %q", channel)
+	}
+
+	return nil
+}
+
+// DroppedTrades returns the number of the symbol's trades discarded by the trades channel's policy. It is always zero
+// with the default Block policy.
+func (s *Stream) DroppedTrades(symbol string) uint64 {
+	return s.trades.lostFor(symbol)
+}
+
+// Diagnostics returns delivery and drop counts for every channel
+func (s *Stream) Diagnostics() []ChannelStats {
+	stats := []ChannelStats{
+		s.trades.stats(),
+		s.quotes.stats(),
+		s.bars.stats(),
+	}
+
+	if s.updatedBars != nil {
+		stats = append(stats, s.updatedBars.stats())
+	}
+
+	if s.dailyBars != nil {
+		stats = append(stats, s.dailyBars.stats())
+	}
+
+	if s.tradingStatus != nil {
+		stats = append(stats, s.tradingStatus.stats())
+	}
+
+	if s.lulds != nil {
+		stats = append(stats, s.lulds.stats())
+	}
+
+	if s.corrections != nil {
+		stats = append(stats, s.corrections.stats())
+	}
+
+	if s.cancelErrors != nil {
+		stats = append(stats, s.cancelErrors.stats())
+	}
+
+	return stats
 }
@@ -0,0 +1,156 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/source"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"testing"
+	"time"
+)
+
+// connect starts a stream against the factory's source and waits for it to connect
+func connect(t *testing.T, factory source.StreamFactory, channels Channels) *Stream {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	channels.Statuses = make(chan Status, 10)
+
+	s, err := NewStream(ctx, factory, channels)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		select {
+		case status := <-channels.Statuses:
+			if status.State == Connected {
+				return s
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not connect")
+		}
+	}
+}
+
+func TestStreamForwardsSubscribedTrades(t *testing.T) {
+	market := fake.NewMarket(fake.Options{})
+	trades := make(chan stream.Trade, 10)
+
+	s := connect(t, market.Factory(), Channels{
+		Trades: trades,
+		Quotes: make(chan stream.Quote, 10),
+		Bars:   make(chan stream.Bar, 10),
+	})
+
+	if err := s.Subscribe("test", "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+
+	market.PushTrade(stream.Trade{Symbol: "MSFT", Price: 1, Size: 1})
+	market.PushTrade(stream.Trade{Symbol: "AAPL", Price: 190.5, Size: 100})
+
+	select {
+	case trade := <-trades:
+		if trade.Symbol != "AAPL" || trade.Price != 190.5 {
+			t.Fatalf("unexpected trade %+v", trade)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("trade was not forwarded")
+	}
+
+	if err := s.Unsubscribe("test", "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+
+	market.PushTrade(stream.Trade{Symbol: "AAPL", Price: 191, Size: 100})
+
+	select {
+	case trade := <-trades:
+		t.Fatalf("unsubscribed trade forwarded: %+v", trade)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStreamBlocksTradesByDefault(t *testing.T) {
+	market := fake.NewMarket(fake.Options{})
+	trades := make(chan stream.Trade, 1)
+
+	s := connect(t, market.Factory(), Channels{
+		Trades: trades,
+		Quotes: make(chan stream.Quote, 10),
+		Bars:   make(chan stream.Bar, 10),
+	})
+
+	if err := s.Subscribe("test", "AAPL"); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for i := 1; i <= 5; i++ {
+			market.PushTrade(stream.Trade{ID: int64(i), Symbol: "AAPL", Price: 100, Size: 1})
+		}
+	}()
+
+	for i := 1; i <= 5; i++ {
+		select {
+		case trade := <-trades:
+			if trade.ID != int64(i) {
+				t.Fatalf("expected trade %d, got %d", i, trade.ID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("trade %d was not forwarded", i)
+		}
+	}
+
+	if dropped := s.DroppedTrades("AAPL"); dropped != 0 {
+		t.Fatalf("expected no dropped trades, got %d", dropped)
+	}
+}
+
+// rejectingSource fails trade subscriptions for the rejected symbol
+type rejectingSource struct {
+	source.StreamSource
+	rejected string
+}
+
+func (r *rejectingSource) SubscribeToTrades(handler func(stream.Trade), symbols ...string) error {
+	for _, symbol := range symbols {
+		if symbol == r.rejected {
+			return errors.New("subscription rejected")
+		}
+	}
+
+	return r.StreamSource.SubscribeToTrades(handler, symbols...)
+}
+
+func TestStreamSetSymbolsKeepsPreviousSymbolsOnError(t *testing.T) {
+	market := fake.NewMarket(fake.Options{})
+	factory := func(onConnect, onDisconnect func()) source.StreamSource {
+		return &rejectingSource{StreamSource: market.Factory()(onConnect, onDisconnect), rejected: "TSLA"}
+	}
+
+	s := connect(t, factory, Channels{
+		Trades: make(chan stream.Trade, 10),
+		Quotes: make(chan stream.Quote, 10),
+		Bars:   make(chan stream.Bar, 10),
+	})
+
+	if err := s.SetSymbols("watchlist", "AAPL", "MSFT"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetSymbols("watchlist", "AAPL", "TSLA"); err == nil {
+		t.Fatal("expected the rejected subscription to fail")
+	}
+
+	subscriptions := s.Subscriptions()
+
+	if len(subscriptions) != 2 || subscriptions[0].Symbol != "AAPL" || subscriptions[1].Symbol != "MSFT" {
+		t.Fatalf("expected the previous symbols, got %+v", subscriptions)
+	}
+}
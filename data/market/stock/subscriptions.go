@@ -0,0 +1,174 @@
+package stock
+
+import (
+	"sort"
+	"strings"
+)
+
+// Subscription describes a streamed symbol and the consumers that want it
+type Subscription struct {
+	Symbol    string   `json:"symbol"`
+	Consumers []string `json:"consumers"`
+	RefCount  int      `json:"refCount"`
+}
+
+// subscriptions reference counts symbols by consumer so that a symbol is only
+// subscribed upstream while at least one consumer wants it.
+type subscriptions struct {
+	consumers map[string]map[string]bool
+	refCounts map[string]int
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		consumers: make(map[string]map[string]bool),
+		refCounts: make(map[string]int),
+	}
+}
+
+// add registers the symbols for the consumer, returning the symbols that were not previously wanted by anyone
+func (s *subscriptions) add(consumer string, symbols ...string) []string {
+	wanted, ok := s.consumers[consumer]
+
+	if !ok {
+		wanted = make(map[string]bool)
+		s.consumers[consumer] = wanted
+	}
+
+	var added []string
+
+	for _, symbol := range normalizeSymbols(symbols) {
+		if wanted[symbol] {
+			continue
+		}
+
+		wanted[symbol] = true
+		s.refCounts[symbol]++
+
+		if s.refCounts[symbol] == 1 {
+			added = append(added, symbol)
+		}
+	}
+
+	return added
+}
+
+// remove releases the symbols held by the consumer, returning the symbols that are no longer wanted by anyone
+func (s *subscriptions) remove(consumer string, symbols ...string) []string {
+	wanted, ok := s.consumers[consumer]
+
+	if !ok {
+		return nil
+	}
+
+	var removed []string
+
+	for _, symbol := range normalizeSymbols(symbols) {
+		if !wanted[symbol] {
+			continue
+		}
+
+		delete(wanted, symbol)
+		s.refCounts[symbol]--
+
+		if s.refCounts[symbol] == 0 {
+			delete(s.refCounts, symbol)
+			removed = append(removed, symbol)
+		}
+	}
+
+	if len(wanted) == 0 {
+		delete(s.consumers, consumer)
+	}
+
+	return removed
+}
+
+// replace sets the consumer's symbols to exactly the given symbols, returning the upstream additions and removals
+func (s *subscriptions) replace(consumer string, symbols ...string) (added []string, removed []string) {
+	next := make(map[string]bool)
+
+	for _, symbol := range normalizeSymbols(symbols) {
+		next[symbol] = true
+	}
+
+	var stale []string
+
+	for symbol := range s.consumers[consumer] {
+		if !next[symbol] {
+			stale = append(stale, symbol)
+		}
+	}
+
+	removed = s.remove(consumer, stale...)
+	added = s.add(consumer, symbols...)
+
+	return added, removed
+}
+
+// of returns the consumer's symbols
+func (s *subscriptions) of(consumer string) []string {
+	symbols := make([]string, 0, len(s.consumers[consumer]))
+
+	for symbol := range s.consumers[consumer] {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+// symbols returns every symbol wanted by at least one consumer
+func (s *subscriptions) symbols() []string {
+	symbols := make([]string, 0, len(s.refCounts))
+
+	for symbol := range s.refCounts {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Strings(symbols)
+
+	return symbols
+}
+
+func (s *subscriptions) list() []Subscription {
+	list := make([]Subscription, 0, len(s.refCounts))
+
+	for _, symbol := range s.symbols() {
+		subscription := Subscription{
+			Symbol:   symbol,
+			RefCount: s.refCounts[symbol],
+		}
+
+		for consumer, wanted := range s.consumers {
+			if wanted[symbol] {
+				subscription.Consumers = append(subscription.Consumers, consumer)
+			}
+		}
+
+		sort.Strings(subscription.Consumers)
+
+		list = append(list, subscription)
+	}
+
+	return list
+}
+
+func normalizeSymbols(symbols []string) []string {
+	normalized := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(strings.ToUpper(symbol))
+
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+
+		seen[symbol] = true
+		normalized = append(normalized, symbol)
+	}
+
+	return normalized
+}
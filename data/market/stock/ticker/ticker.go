@@ -0,0 +1,331 @@
+package ticker
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/stock/sales"
+	"strings"
+	"sync"
+	"time"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// Ticker is a symbol's latest price, quote and statistics for its current trading session
+type Ticker struct {
+	Symbol string `json:"symbol"`
+	// SessionDate is the New York date of the session the statistics belong to
+	SessionDate   string    `json:"sessionDate"`
+	Last          float64   `json:"last"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	Bid           float64   `json:"bid"`
+	BidSize       uint32    `json:"bidSize"`
+	Ask           float64   `json:"ask"`
+	AskSize       uint32    `json:"askSize"`
+	Mid           float64   `json:"mid"`
+	Spread        float64   `json:"spread"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
+	Volume        uint64    `json:"volume"`
+	VWAP          float64   `json:"vwap"`
+	PreviousClose float64   `json:"previousClose"`
+	Change        float64   `json:"change"`
+	// ChangePercent is the change from the previous close as a percentage, e.g. 1.5 for 1.5%
+	ChangePercent float64 `json:"changePercent"`
+	// DroppedTrades is the number of trades discarded by the stream, missing from the volume and VWAP
+	DroppedTrades uint64 `json:"droppedTrades"`
+	// notional is the volume weighted sum of prices used to calculate the VWAP
+	notional float64
+}
+
+// Tickers are the tickers that changed since they were last emitted
+type Tickers map[string]Ticker
+
+func sessionDate(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+// roll starts a new session, closing the previous one at the last price
+func (t *Ticker) roll(date string) {
+	if t.Last > 0 {
+		t.PreviousClose = t.Last
+	}
+
+	t.SessionDate = date
+	t.Open = 0
+	t.High = 0
+	t.Low = 0
+	t.Volume = 0
+	t.notional = 0
+}
+
+// session returns false if the timestamp belongs to an earlier session, rolling to a new session if it is later
+func (t *Ticker) session(timestamp time.Time) bool {
+	date := sessionDate(timestamp)
+
+	if date < t.SessionDate {
+		return false
+	}
+
+	if date > t.SessionDate {
+		t.roll(date)
+	}
+
+	return true
+}
+
+func (t *Ticker) applyDailyBar(bar marketdata.Bar) {
+	t.Open = bar.Open
+	t.High = bar.High
+	t.Low = bar.Low
+	t.Volume = bar.Volume
+	t.notional = bar.VWAP * float64(bar.Volume)
+}
+
+func (t *Ticker) applyQuote(bid float64, bidSize uint32, ask float64, askSize uint32) {
+	t.Bid = bid
+	t.BidSize = bidSize
+	t.Ask = ask
+	t.AskSize = askSize
+	t.Mid = 0
+	t.Spread = 0
+
+	if bid > 0 && ask > 0 {
+		t.Mid = (bid + ask) / 2
+		t.Spread = ask - bid
+	}
+}
+
+// calculate updates the values derived from the last price and totals
+func (t *Ticker) calculate() {
+	t.VWAP = 0
+
+	if t.Volume > 0 {
+		t.VWAP = t.notional / float64(t.Volume)
+	}
+
+	t.Change = 0
+	t.ChangePercent = 0
+
+	if t.PreviousClose > 0 && t.Last > 0 {
+		t.Change = t.Last - t.PreviousClose
+		t.ChangePercent = t.Change / t.PreviousClose * 100
+	}
+}
+
+// Store maintains a ticker per symbol
+type Store struct {
+	mut     sync.RWMutex
+	tickers map[string]*Ticker
+}
+
+func NewStore() *Store {
+	return &Store{
+		tickers: make(map[string]*Ticker),
+	}
+}
+
+func (s *Store) ticker(symbol string) *Ticker {
+	t, ok := s.tickers[symbol]
+
+	if !ok {
+		t = &Ticker{Symbol: symbol}
+		s.tickers[symbol] = t
+	}
+
+	return t
+}
+
+// FromSnapshot returns the symbol's ticker for the session its snapshot's latest trade belongs to
+func FromSnapshot(symbol string, snapshot *marketdata.Snapshot) Ticker {
+	t := Ticker{Symbol: strings.ToUpper(symbol)}
+	t.seed(snapshot)
+
+	return t
+}
+
+// Seed sets the symbol's session statistics from a snapshot. The session is the one the latest trade belongs to,
+// so overnight, at weekends and before the first trade of the pre-market the previous session is still shown.
+func (s *Store) Seed(symbol string, snapshot *marketdata.Snapshot) Ticker {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t := s.ticker(strings.ToUpper(symbol))
+	t.seed(snapshot)
+
+	return *t
+}
+
+func (t *Ticker) seed(snapshot *marketdata.Snapshot) {
+	if snapshot == nil {
+		return
+	}
+
+	var date string
+
+	if snapshot.LatestTrade != nil {
+		date = sessionDate(snapshot.LatestTrade.Timestamp)
+	} else if snapshot.DailyBar != nil {
+		date = sessionDate(snapshot.DailyBar.Timestamp)
+	}
+
+	if date < t.SessionDate {
+		return
+	}
+
+	if date > t.SessionDate {
+		t.roll(date)
+	}
+
+	if snapshot.DailyBar != nil {
+		if sessionDate(snapshot.DailyBar.Timestamp) == date {
+			t.applyDailyBar(*snapshot.DailyBar)
+
+			if snapshot.PrevDailyBar != nil {
+				t.PreviousClose = snapshot.PrevDailyBar.Close
+			}
+		} else {
+			// the session has started but has no daily bar yet
+			t.PreviousClose = snapshot.DailyBar.Close
+		}
+	}
+
+	if trade := snapshot.LatestTrade; trade != nil && !trade.Timestamp.Before(t.LastTimestamp) {
+		t.Last = trade.Price
+		t.LastTimestamp = trade.Timestamp
+	}
+
+	if quote := snapshot.LatestQuote; quote != nil {
+		t.applyQuote(quote.BidPrice, quote.BidSize, quote.AskPrice, quote.AskSize)
+	}
+
+	t.calculate()
+}
+
+// ApplyTrade updates the ticker with a trade recorded by the ledger, honoring its condition eligibility
+func (s *Store) ApplyTrade(symbol string, trade sales.Trade) Ticker {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t := s.ticker(symbol)
+
+	if !t.session(trade.Timestamp) {
+		return *t
+	}
+
+	if trade.Eligibility.UpdatesLast && !trade.Timestamp.Before(t.LastTimestamp) {
+		t.Last = trade.Price
+		t.LastTimestamp = trade.Timestamp
+	}
+
+	if trade.Eligibility.UpdatesHighLow {
+		if t.Open == 0 {
+			t.Open = trade.Price
+		}
+
+		if t.High == 0 || trade.Price > t.High {
+			t.High = trade.Price
+		}
+
+		if t.Low == 0 || trade.Price < t.Low {
+			t.Low = trade.Price
+		}
+	}
+
+	if trade.Eligibility.UpdatesVolume {
+		t.Volume += uint64(trade.Size)
+		t.notional += trade.Price * float64(trade.Size)
+	}
+
+	t.calculate()
+
+	return *t
+}
+
+// ApplyAmendment takes a corrected or canceled trade out of the volume and VWAP, adds the corrected trade back and
+// takes the last price from the ledger. The high and low are left until the next daily bar.
+func (s *Store) ApplyAmendment(amendment sales.Amendment) Ticker {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t := s.ticker(amendment.Symbol)
+
+	if original := amendment.Original; original != nil && !original.Canceled &&
+		original.Eligibility.UpdatesVolume && sessionDate(original.Timestamp) == t.SessionDate &&
+		t.Volume >= uint64(original.Size) {
+		t.Volume -= uint64(original.Size)
+		t.notional -= original.Price * float64(original.Size)
+	}
+
+	if corrected := amendment.Corrected; corrected != nil && !corrected.Canceled &&
+		corrected.Eligibility.UpdatesVolume && sessionDate(corrected.Timestamp) == t.SessionDate {
+		t.Volume += uint64(corrected.Size)
+		t.notional += corrected.Price * float64(corrected.Size)
+	}
+
+	if summary := amendment.Summary; summary.LastPrice > 0 && sessionDate(summary.LastTimestamp) == t.SessionDate {
+		t.Last = summary.LastPrice
+		t.LastTimestamp = summary.LastTimestamp
+	}
+
+	t.calculate()
+
+	return *t
+}
+
+// ApplyQuote updates the ticker's bid, ask, mid and spread
+func (s *Store) ApplyQuote(quote stream.Quote) Ticker {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t := s.ticker(quote.Symbol)
+	t.applyQuote(quote.BidPrice, quote.BidSize, quote.AskPrice, quote.AskSize)
+
+	return *t
+}
+
+// ApplyDailyBar replaces the session's open, high, low, volume and VWAP with the streamed daily bar
+func (s *Store) ApplyDailyBar(bar stream.Bar) Ticker {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	t := s.ticker(bar.Symbol)
+
+	if !t.session(bar.Timestamp) {
+		return *t
+	}
+
+	t.applyDailyBar(marketdata.Bar{
+		Open:   bar.Open,
+		High:   bar.High,
+		Low:    bar.Low,
+		Volume: bar.Volume,
+		VWAP:   bar.VWAP,
+	})
+	t.calculate()
+
+	return *t
+}
+
+// Get returns the symbol's ticker
+func (s *Store) Get(symbol string) Ticker {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	symbol = strings.ToUpper(symbol)
+
+	if t, ok := s.tickers[symbol]; ok {
+		return *t
+	}
+
+	return Ticker{Symbol: symbol}
+}
+
+// Remove forgets the symbol's ticker
+func (s *Store) Remove(symbol string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	delete(s.tickers, strings.ToUpper(symbol))
+}
@@ -0,0 +1,128 @@
+package ticker
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"github.com/phoobynet/buffalo/data/market/stock/conditions"
+	"github.com/phoobynet/buffalo/data/market/stock/sales"
+	"math"
+	"testing"
+	"time"
+)
+
+var (
+	regular = conditions.Eligibility{UpdatesLast: true, UpdatesHighLow: true, UpdatesVolume: true}
+	oddLot  = conditions.Eligibility{UpdatesVolume: true}
+)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, newYork)
+}
+
+func trade(price float64, size uint32, timestamp time.Time, eligibility conditions.Eligibility) sales.Trade {
+	return sales.Trade{Price: price, Size: size, Timestamp: timestamp, Eligibility: eligibility}
+}
+
+// expectTicker compares the ticker's session, last price, totals and the values derived from them
+func expectTicker(t *testing.T, name string, actual Ticker, date string, last, previousClose float64, volume uint64, vwap float64) {
+	t.Helper()
+
+	change := last - previousClose
+
+	if actual.SessionDate != date || actual.Last != last || actual.PreviousClose != previousClose ||
+		actual.Volume != volume || math.Abs(actual.VWAP-vwap) > 1e-9 || math.Abs(actual.Change-change) > 1e-9 ||
+		math.Abs(actual.ChangePercent-change/previousClose*100) > 1e-9 {
+		t.Fatalf("%s: expected %s last %.2f from %.2f, volume %d at %.4f, got %+v",
+			name, date, last, previousClose, volume, vwap, actual)
+	}
+}
+
+func TestSessionRollover(t *testing.T) {
+	s := NewStore()
+
+	// Thursday's close
+	s.Seed("aapl", &marketdata.Snapshot{
+		LatestTrade:  &marketdata.Trade{Price: 110, Timestamp: at(15, 19, 59)},
+		DailyBar:     &marketdata.Bar{Timestamp: at(15, 0, 0), Open: 101, High: 112, Low: 99, Volume: 1_000, VWAP: 105},
+		PrevDailyBar: &marketdata.Bar{Timestamp: at(14, 0, 0), Close: 100},
+	})
+
+	expectTicker(t, "seeded", s.Get("AAPL"), "2026-10-15", 110, 100, 1_000, 105)
+
+	// Friday's first pre-market trade closes Thursday at its last price
+	first := s.ApplyTrade("AAPL", trade(111, 100, at(16, 4, 0), regular))
+	expectTicker(t, "rolled", first, "2026-10-16", 111, 110, 100, 111)
+
+	if first.Open != 111 || first.High != 111 || first.Low != 111 {
+		t.Fatalf("expected the session's range to restart, got %+v", first)
+	}
+
+	// a late trade from Thursday is ignored
+	expectTicker(t, "late trade", s.ApplyTrade("AAPL", trade(90, 500, at(15, 19, 59), regular)), "2026-10-16", 111, 110, 100, 111)
+
+	s.ApplyTrade("AAPL", trade(113, 300, at(16, 4, 1), regular))
+	expectTicker(t, "second trade", s.Get("AAPL"), "2026-10-16", 113, 110, 400, 112.5)
+
+	// an odd lot counts towards the volume and VWAP, but not the last price
+	s.ApplyTrade("AAPL", trade(120, 100, at(16, 4, 2), oddLot))
+	expectTicker(t, "odd lot", s.Get("AAPL"), "2026-10-16", 113, 110, 500, 114)
+
+	// a daily bar from Thursday no longer applies
+	s.ApplyDailyBar(stream.Bar{Symbol: "AAPL", Timestamp: at(15, 0, 0), Open: 1, High: 1, Low: 1, Volume: 1, VWAP: 1})
+	expectTicker(t, "late daily bar", s.Get("AAPL"), "2026-10-16", 113, 110, 500, 114)
+
+	s.ApplyDailyBar(stream.Bar{Symbol: "AAPL", Timestamp: at(16, 0, 0), Open: 111, High: 120, Low: 111, Volume: 600, VWAP: 115})
+	expectTicker(t, "daily bar", s.Get("AAPL"), "2026-10-16", 113, 110, 600, 115)
+}
+
+func TestAmendmentAcrossSessions(t *testing.T) {
+	s := NewStore()
+
+	s.ApplyTrade("AAPL", trade(100, 100, at(15, 15, 59), regular))
+	s.ApplyTrade("AAPL", trade(110, 100, at(16, 9, 30), regular))
+	s.ApplyTrade("AAPL", trade(120, 100, at(16, 9, 31), regular))
+
+	// a cancel of Thursday's trade leaves Friday's totals alone
+	thursday := trade(100, 100, at(15, 15, 59), regular)
+	actual := s.ApplyAmendment(sales.Amendment{
+		Symbol:   "AAPL",
+		Original: &thursday,
+		Summary:  sales.Summary{LastPrice: 120, LastTimestamp: at(16, 9, 31)},
+	})
+
+	expectTicker(t, "Thursday's cancel", actual, "2026-10-16", 120, 100, 200, 115)
+
+	canceled := trade(120, 100, at(16, 9, 31), regular)
+	actual = s.ApplyAmendment(sales.Amendment{
+		Symbol:   "AAPL",
+		Original: &canceled,
+		Summary:  sales.Summary{LastPrice: 110, LastTimestamp: at(16, 9, 30)},
+	})
+
+	expectTicker(t, "canceled", actual, "2026-10-16", 110, 100, 100, 110)
+
+	original := trade(110, 100, at(16, 9, 30), regular)
+	corrected := trade(112, 50, at(16, 9, 30), regular)
+	actual = s.ApplyAmendment(sales.Amendment{
+		Symbol:    "AAPL",
+		Original:  &original,
+		Corrected: &corrected,
+		Summary:   sales.Summary{LastPrice: 112, LastTimestamp: at(16, 9, 30)},
+	})
+
+	expectTicker(t, "corrected", actual, "2026-10-16", 112, 100, 50, 112)
+}
+
+func TestSeedBeforeTheDailyBar(t *testing.T) {
+	// Friday's pre-market has traded, but the snapshot's daily bar is still Thursday's
+	actual := FromSnapshot("aapl", &marketdata.Snapshot{
+		LatestTrade: &marketdata.Trade{Price: 105, Timestamp: at(16, 4, 0)},
+		DailyBar:    &marketdata.Bar{Timestamp: at(15, 0, 0), Close: 100, Volume: 1_000, VWAP: 99},
+	})
+
+	expectTicker(t, "pre-market", actual, "2026-10-16", 105, 100, 0, 0)
+
+	if actual.Symbol != "AAPL" || actual.Open != 0 {
+		t.Fatalf("unexpected ticker %+v", actual)
+	}
+}
@@ -0,0 +1,118 @@
+package trading
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"strings"
+	"sync"
+	"time"
+)
+
+// haltCodes are the CTA and UTP status codes that stop trading in a symbol
+var haltCodes = map[string]bool{
+	"2": true, // CTA trading halt
+	"H": true, // UTP trading halt
+	"P": true, // UTP volatility trading pause
+	"Q": true, // UTP quotation resumption, quoting only until trading resumes
+}
+
+// resumeCodes are the CTA and UTP status codes that resume trading in a symbol
+var resumeCodes = map[string]bool{
+	"3": true, // CTA resume
+	"T": true, // UTP trading resumption
+}
+
+// State is the halt status and limit up/limit down bands of a symbol
+type State struct {
+	Symbol         string    `json:"symbol"`
+	Halted         bool      `json:"halted"`
+	StatusCode     string    `json:"statusCode,omitempty"`
+	StatusMsg      string    `json:"statusMsg,omitempty"`
+	ReasonCode     string    `json:"reasonCode,omitempty"`
+	ReasonMsg      string    `json:"reasonMsg,omitempty"`
+	HaltedAt       time.Time `json:"haltedAt,omitempty"`
+	ResumedAt      time.Time `json:"resumedAt,omitempty"`
+	LimitUpPrice   float64   `json:"limitUpPrice,omitempty"`
+	LimitDownPrice float64   `json:"limitDownPrice,omitempty"`
+	LULDIndicator  string    `json:"luldIndicator,omitempty"`
+	LULDUpdatedAt  time.Time `json:"luldUpdatedAt,omitempty"`
+}
+
+// Tracker maintains the trading State of every symbol it receives messages for
+type Tracker struct {
+	mut    sync.RWMutex
+	states map[string]*State
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		states: make(map[string]*State),
+	}
+}
+
+func (t *Tracker) state(symbol string) *State {
+	state, ok := t.states[symbol]
+
+	if !ok {
+		state = &State{Symbol: symbol}
+		t.states[symbol] = state
+	}
+
+	return state
+}
+
+// ApplyStatus updates the symbol's halt status, returning the new state
+func (t *Tracker) ApplyStatus(status stream.TradingStatus) State {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	state := t.state(status.Symbol)
+	code := strings.ToUpper(status.StatusCode)
+
+	switch {
+	case haltCodes[code]:
+		if !state.Halted {
+			state.HaltedAt = status.Timestamp
+		}
+
+		state.Halted = true
+	case resumeCodes[code]:
+		if state.Halted {
+			state.ResumedAt = status.Timestamp
+		}
+
+		state.Halted = false
+	}
+
+	state.StatusCode = status.StatusCode
+	state.StatusMsg = status.StatusMsg
+	state.ReasonCode = status.ReasonCode
+	state.ReasonMsg = status.ReasonMsg
+
+	return *state
+}
+
+// ApplyLULD updates the symbol's limit up/limit down bands, returning the new state
+func (t *Tracker) ApplyLULD(luld stream.LULD) State {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	state := t.state(luld.Symbol)
+	state.LimitUpPrice = luld.LimitUpPrice
+	state.LimitDownPrice = luld.LimitDownPrice
+	state.LULDIndicator = luld.Indicator
+	state.LULDUpdatedAt = luld.Timestamp
+
+	return *state
+}
+
+// Get returns the trading state of the symbol
+func (t *Tracker) Get(symbol string) State {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+
+	if state, ok := t.states[strings.ToUpper(symbol)]; ok {
+		return *state
+	}
+
+	return State{Symbol: strings.ToUpper(symbol)}
+}
@@ -0,0 +1,124 @@
+package venues
+
+import (
+	"sort"
+)
+
+// Volume is a venue's share of a symbol's volume and trades for the day
+type Volume struct {
+	Exchange   Exchange `json:"exchange"`
+	Volume     uint64   `json:"volume"`
+	TradeCount uint64   `json:"tradeCount"`
+	// Share is the venue's fraction of the symbol's volume, between 0 and 1
+	Share float64 `json:"share"`
+}
+
+// Breakdown is a symbol's intraday volume and trade count by venue, largest venue first
+type Breakdown struct {
+	Symbol     string   `json:"symbol"`
+	Venues     []Volume `json:"venues"`
+	Volume     uint64   `json:"volume"`
+	TradeCount uint64   `json:"tradeCount"`
+	// OffExchangeVolume is the volume reported to a trade reporting facility rather than executed on an exchange
+	OffExchangeVolume uint64  `json:"offExchangeVolume"`
+	OffExchangeShare  float64 `json:"offExchangeShare"`
+	// DroppedTrades is the number of trades discarded by the stream, missing from the volumes and counts
+	DroppedTrades uint64 `json:"droppedTrades"`
+}
+
+type counts struct {
+	volume     uint64
+	tradeCount uint64
+}
+
+// Tally counts volume and trades by exchange code
+type Tally map[string]*counts
+
+func (t Tally) counts(exchange string) *counts {
+	c, ok := t[exchange]
+
+	if !ok {
+		c = &counts{}
+		t[exchange] = c
+	}
+
+	return c
+}
+
+// Add counts a trade on the exchange, including its size in the volume if its conditions allow it
+func (t Tally) Add(exchange string, size uint32, updatesVolume bool) {
+	c := t.counts(exchange)
+	c.tradeCount++
+
+	if updatesVolume {
+		c.volume += uint64(size)
+	}
+}
+
+// Remove takes a canceled trade out of the exchange's trade count. Its volume is taken out with SubtractVolume.
+func (t Tally) Remove(exchange string) {
+	if c := t.counts(exchange); c.tradeCount > 0 {
+		c.tradeCount--
+	}
+}
+
+// SubtractVolume takes the size out of the exchange's volume without changing its trade count
+func (t Tally) SubtractVolume(exchange string, size uint32, updatesVolume bool) {
+	if c := t.counts(exchange); updatesVolume && c.volume >= uint64(size) {
+		c.volume -= uint64(size)
+	}
+}
+
+// AddVolume adds the size to the exchange's volume without changing its trade count
+func (t Tally) AddVolume(exchange string, size uint32, updatesVolume bool) {
+	if updatesVolume {
+		t.counts(exchange).volume += uint64(size)
+	}
+}
+
+// Breakdown returns the symbol's volume by venue
+func (t Tally) Breakdown(symbol string) Breakdown {
+	breakdown := Breakdown{
+		Symbol: symbol,
+		Venues: make([]Volume, 0, len(t)),
+	}
+
+	for code, c := range t {
+		if c.tradeCount == 0 && c.volume == 0 {
+			continue
+		}
+
+		exchange, _ := Lookup(code)
+
+		breakdown.Venues = append(breakdown.Venues, Volume{
+			Exchange:   exchange,
+			Volume:     c.volume,
+			TradeCount: c.tradeCount,
+		})
+
+		breakdown.Volume += c.volume
+		breakdown.TradeCount += c.tradeCount
+
+		if exchange.OffExchange {
+			breakdown.OffExchangeVolume += c.volume
+		}
+	}
+
+	if breakdown.Volume > 0 {
+		for i := range breakdown.Venues {
+			breakdown.Venues[i].Share = float64(breakdown.Venues[i].Volume) / float64(breakdown.Volume)
+		}
+
+		breakdown.OffExchangeShare = float64(breakdown.OffExchangeVolume) / float64(breakdown.Volume)
+	}
+
+	sort.Slice(breakdown.Venues, func(i, j int) bool {
+		if breakdown.Venues[i].Volume == breakdown.Venues[j].Volume {
+			return breakdown.Venues[i].Exchange.Code < breakdown.Venues[j].Exchange.Code
+		}
+
+		return breakdown.Venues[i].Volume > breakdown.Venues[j].Volume
+	})
+
+	return breakdown
+}
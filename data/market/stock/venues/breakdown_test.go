@@ -0,0 +1,139 @@
+package venues
+
+import (
+	"testing"
+)
+
+// venue returns the breakdown's volume and trade count for the exchange code
+func venue(breakdown Breakdown, code string) (Volume, bool) {
+	for _, v := range breakdown.Venues {
+		if v.Exchange.Code == code {
+			return v, true
+		}
+	}
+
+	return Volume{}, false
+}
+
+func TestTallyAmendments(t *testing.T) {
+	tally := make(Tally)
+
+	tally.Add("Q", 100, true)
+	tally.Add("D", 300, true)
+	// an odd lot is counted as a trade without volume
+	tally.Add("Q", 50, false)
+
+	tests := []struct {
+		name               string
+		amend              func()
+		expectedQ          Volume
+		expectedD          Volume
+		expectedVolume     uint64
+		expectedTradeCount uint64
+	}{
+		{
+			name:               "trades",
+			amend:              func() {},
+			expectedQ:          Volume{Volume: 100, TradeCount: 2},
+			expectedD:          Volume{Volume: 300, TradeCount: 1},
+			expectedVolume:     400,
+			expectedTradeCount: 3,
+		},
+		{
+			name: "corrected size",
+			amend: func() {
+				tally.SubtractVolume("D", 300, true)
+				tally.AddVolume("D", 200, true)
+			},
+			expectedQ:          Volume{Volume: 100, TradeCount: 2},
+			expectedD:          Volume{Volume: 200, TradeCount: 1},
+			expectedVolume:     300,
+			expectedTradeCount: 3,
+		},
+		{
+			name: "corrected from an odd lot",
+			amend: func() {
+				tally.SubtractVolume("Q", 50, false)
+				tally.AddVolume("Q", 50, true)
+			},
+			expectedQ:          Volume{Volume: 150, TradeCount: 2},
+			expectedD:          Volume{Volume: 200, TradeCount: 1},
+			expectedVolume:     350,
+			expectedTradeCount: 3,
+		},
+		{
+			name: "canceled",
+			amend: func() {
+				tally.SubtractVolume("Q", 100, true)
+				tally.Remove("Q")
+			},
+			expectedQ:          Volume{Volume: 50, TradeCount: 1},
+			expectedD:          Volume{Volume: 200, TradeCount: 1},
+			expectedVolume:     250,
+			expectedTradeCount: 2,
+		},
+		{
+			name: "more than the venue's volume",
+			amend: func() {
+				tally.SubtractVolume("Q", 500, true)
+				tally.Remove("Q")
+				tally.Remove("Q")
+			},
+			expectedQ:          Volume{Volume: 50},
+			expectedD:          Volume{Volume: 200, TradeCount: 1},
+			expectedVolume:     250,
+			expectedTradeCount: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test.amend()
+		breakdown := tally.Breakdown("AAPL")
+
+		q, _ := venue(breakdown, "Q")
+		d, _ := venue(breakdown, "D")
+
+		if q.Volume != test.expectedQ.Volume || q.TradeCount != test.expectedQ.TradeCount ||
+			d.Volume != test.expectedD.Volume || d.TradeCount != test.expectedD.TradeCount {
+			t.Errorf("%s: expected Q %+v and D %+v, got %+v and %+v", test.name, test.expectedQ, test.expectedD, q, d)
+		}
+
+		if breakdown.Volume != test.expectedVolume || breakdown.TradeCount != test.expectedTradeCount ||
+			breakdown.OffExchangeVolume != d.Volume {
+			t.Errorf("%s: unexpected totals %+v", test.name, breakdown)
+		}
+	}
+}
+
+func TestBreakdownShares(t *testing.T) {
+	tally := make(Tally)
+
+	tally.Add("Q", 100, true)
+	tally.Add("N", 100, true)
+	tally.Add("D", 200, true)
+	tally.Add("V", 0, false)
+	// a venue whose only trade was canceled is left out
+	tally.Add("P", 10, true)
+	tally.SubtractVolume("P", 10, true)
+	tally.Remove("P")
+
+	breakdown := tally.Breakdown("AAPL")
+	codes := make([]string, len(breakdown.Venues))
+
+	for i, v := range breakdown.Venues {
+		codes[i] = v.Exchange.Code
+	}
+
+	// ties are ordered by code
+	if len(codes) != 4 || codes[0] != "D" || codes[1] != "N" || codes[2] != "Q" || codes[3] != "V" {
+		t.Fatalf("unexpected venues %v", codes)
+	}
+
+	if breakdown.Venues[0].Share != 0.5 || breakdown.Venues[1].Share != 0.25 || breakdown.OffExchangeShare != 0.5 {
+		t.Fatalf("unexpected shares %+v", breakdown)
+	}
+
+	if empty := make(Tally).Breakdown("AAPL"); len(empty.Venues) != 0 || empty.OffExchangeShare != 0 {
+		t.Fatalf("unexpected empty breakdown %+v", empty)
+	}
+}
@@ -0,0 +1,107 @@
+package venues
+
+import (
+	"sort"
+	"strings"
+)
+
+// Exchange describes a venue identified by the single-letter exchange code carried by trades and quotes
+type Exchange struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// OffExchange is true for trade reporting facilities, which report trades executed away from an exchange
+	OffExchange bool `json:"offExchange"`
+}
+
+// Tape describes a consolidated tape, identifying where the security is listed
+type Tape struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Plan string `json:"plan"`
+}
+
+// exchanges follows the exchange codes used by the CTA and UTP plans
+var exchanges = map[string]Exchange{
+	"A": {Code: "A", Name: "NYSE American"},
+	"B": {Code: "B", Name: "Nasdaq BX"},
+	"C": {Code: "C", Name: "NYSE National"},
+	"D": {Code: "D", Name: "FINRA ADF / TRF", OffExchange: true},
+	"E": {Code: "E", Name: "Market Independent"},
+	"H": {Code: "H", Name: "MIAX Pearl"},
+	"I": {Code: "I", Name: "Nasdaq ISE"},
+	"J": {Code: "J", Name: "Cboe EDGA"},
+	"K": {Code: "K", Name: "Cboe EDGX"},
+	"L": {Code: "L", Name: "Long-Term Stock Exchange"},
+	"M": {Code: "M", Name: "NYSE Chicago"},
+	"N": {Code: "N", Name: "New York Stock Exchange"},
+	"P": {Code: "P", Name: "NYSE Arca"},
+	"Q": {Code: "Q", Name: "Nasdaq"},
+	"S": {Code: "S", Name: "Nasdaq Small Cap"},
+	"T": {Code: "T", Name: "Nasdaq International"},
+	"U": {Code: "U", Name: "Members Exchange"},
+	"V": {Code: "V", Name: "IEX"},
+	"W": {Code: "W", Name: "Cboe"},
+	"X": {Code: "X", Name: "Nasdaq PSX"},
+	"Y": {Code: "Y", Name: "Cboe BYX"},
+	"Z": {Code: "Z", Name: "Cboe BZX"},
+}
+
+var tapes = map[string]Tape{
+	"A": {Code: "A", Name: "NYSE listed", Plan: "CTA"},
+	"B": {Code: "B", Name: "NYSE Arca, NYSE American and regional listed", Plan: "CTA"},
+	"C": {Code: "C", Name: "Nasdaq listed", Plan: "UTP"},
+}
+
+// Lookup returns the exchange for the code. Unknown codes are named after the code itself.
+func Lookup(code string) (Exchange, bool) {
+	code = strings.ToUpper(code)
+	exchange, ok := exchanges[code]
+
+	if !ok {
+		return Exchange{Code: code, Name: code}, false
+	}
+
+	return exchange, true
+}
+
+// Exchanges returns every known exchange, ordered by code
+func Exchanges() []Exchange {
+	list := make([]Exchange, 0, len(exchanges))
+
+	for _, exchange := range exchanges {
+		list = append(list, exchange)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+
+	return list
+}
+
+// LookupTape returns the tape for the code. Unknown codes are named after the code itself.
+func LookupTape(code string) (Tape, bool) {
+	code = strings.ToUpper(code)
+	tape, ok := tapes[code]
+
+	if !ok {
+		return Tape{Code: code, Name: code}, false
+	}
+
+	return tape, true
+}
+
+// Tapes returns every consolidated tape, ordered by code
+func Tapes() []Tape {
+	list := make([]Tape, 0, len(tapes))
+
+	for _, tape := range tapes {
+		list = append(list, tape)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+
+	return list
+}
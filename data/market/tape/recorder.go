@@ -0,0 +1,93 @@
+package tape
+
+import (
+	"context"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+const (
+	recorderBufferSize    = 10_000
+	recorderFlushInterval = time.Second
+	recorderBatchSize     = 500
+)
+
+// Recorder persists stream messages to the tape database. Messages are written in batches
+// from a background goroutine so that recording never blocks the stream.
+type Recorder struct {
+	db      *gorm.DB
+	entries chan *Entry
+}
+
+func NewRecorder(ctx context.Context, db *gorm.DB) *Recorder {
+	r := &Recorder{
+		db:      db,
+		entries: make(chan *Entry, recorderBufferSize),
+	}
+
+	go func(r *Recorder) {
+		ticker := time.NewTicker(recorderFlushInterval)
+		defer ticker.Stop()
+
+		pending := make([]*Entry, 0, recorderBatchSize)
+
+		for {
+			select {
+			case entry := <-r.entries:
+				pending = append(pending, entry)
+
+				if len(pending) >= recorderBatchSize {
+					pending = r.flush(pending)
+				}
+			case <-ticker.C:
+				pending = r.flush(pending)
+			case <-ctx.Done():
+				r.flush(pending)
+				return
+			}
+		}
+	}(r)
+
+	return r
+}
+
+func (r *Recorder) flush(pending []*Entry) []*Entry {
+	if len(pending) == 0 {
+		return pending
+	}
+
+	if err := r.db.CreateInBatches(pending, recorderBatchSize).Error; err != nil {
+		log.Printf("tape: failed to record %d entries: %v", len(pending), err)
+	}
+
+	return pending[:0]
+}
+
+func (r *Recorder) record(kind Kind, symbol string, timestamp time.Time, message any) {
+	entry, err := newEntry(kind, symbol, timestamp, message)
+
+	if err != nil {
+		log.Printf("tape: failed to encode %s for %s: %v", kind, symbol, err)
+		return
+	}
+
+	select {
+	case r.entries <- entry:
+	default:
+		log.Printf("tape: recorder buffer full, dropping %s for %s", kind, symbol)
+	}
+}
+
+func (r *Recorder) RecordTrade(trade stream.Trade) {
+	r.record(TradeKind, trade.Symbol, trade.Timestamp, trade)
+}
+
+func (r *Recorder) RecordQuote(quote stream.Quote) {
+	r.record(QuoteKind, quote.Symbol, quote.Timestamp, quote)
+}
+
+func (r *Recorder) RecordBar(bar stream.Bar) {
+	r.record(BarKind, bar.Symbol, bar.Timestamp, bar)
+}
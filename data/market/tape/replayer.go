@@ -0,0 +1,257 @@
+package tape
+
+import (
+	"context"
+	"errors"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"gorm.io/gorm"
+	"log"
+	"sync"
+	"time"
+)
+
+const replayPageSize = 1_000
+
+var ErrReplayInProgress = errors.New("a replay is already in progress")
+
+// ReplayOptions controls how a recorded tape is played back
+type ReplayOptions struct {
+	// Speed is the playback rate relative to the recorded timestamps, e.g. 1 is real time and 10 is ten times
+	// faster. Zero or less plays back as fast as the channels allow.
+	Speed float64 `json:"speed"`
+	// Step pauses before every message until Step is called
+	Step bool `json:"step"`
+	// Symbols restricts playback to the given symbols, or every symbol if empty
+	Symbols []string `json:"symbols"`
+	// Start and End restrict playback to messages timestamped within the range, if not zero
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Replayer feeds a recorded tape back into the channels used by the live stream
+type Replayer struct {
+	mut    sync.Mutex
+	db     *gorm.DB
+	trades chan stream.Trade
+	quotes chan stream.Quote
+	bars   chan stream.Bar
+	step   chan struct{}
+	replay *replay
+}
+
+type replay struct {
+	cancel context.CancelFunc
+}
+
+func NewReplayer(db *gorm.DB, trades chan stream.Trade, quotes chan stream.Quote, bars chan stream.Bar) *Replayer {
+	return &Replayer{
+		db:     db,
+		trades: trades,
+		quotes: quotes,
+		bars:   bars,
+		// a step sent while a message is being delivered is kept for the next one
+		step: make(chan struct{}, 1),
+	}
+}
+
+// Play starts playing back the tape in the background, failing if a replay is already in progress
+func (r *Replayer) Play(ctx context.Context, options ReplayOptions) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.replay != nil {
+		return ErrReplayInProgress
+	}
+
+	// discard a step left over from a previous replay
+	select {
+	case <-r.step:
+	default:
+	}
+
+	replayCtx, cancel := context.WithCancel(ctx)
+	current := &replay{cancel: cancel}
+	r.replay = current
+
+	go func() {
+		defer r.finish(current)
+
+		err := r.play(replayCtx, options)
+
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("replay failed: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Step releases the next message when playing back in step mode. Steps beyond the next message are ignored.
+func (r *Replayer) Step() {
+	select {
+	case r.step <- struct{}{}:
+	default:
+	}
+}
+
+// Stop ends the current replay, if any
+func (r *Replayer) Stop() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.replay != nil {
+		r.replay.cancel()
+		r.replay = nil
+	}
+}
+
+func (r *Replayer) finish(finished *replay) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	finished.cancel()
+
+	if r.replay == finished {
+		r.replay = nil
+	}
+}
+
+// IsPlaying returns true if a replay is in progress
+func (r *Replayer) IsPlaying() bool {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	return r.replay != nil
+}
+
+func (r *Replayer) play(ctx context.Context, options ReplayOptions) error {
+	var lastID uint
+	var previous time.Time
+
+	for {
+		entries, err := r.page(lastID, options)
+
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		for _, entry := range entries {
+			if err := r.wait(ctx, options, previous, entry.Timestamp); err != nil {
+				return err
+			}
+
+			if err := r.send(ctx, entry); err != nil {
+				return err
+			}
+
+			previous = entry.Timestamp
+			lastID = entry.ID
+		}
+	}
+}
+
+func (r *Replayer) page(afterID uint, options ReplayOptions) ([]Entry, error) {
+	var entries []Entry
+
+	query := r.db.
+		Model(&Entry{}).
+		Where("id > ?", afterID)
+
+	if len(options.Symbols) > 0 {
+		query = query.Where("symbol IN ?", options.Symbols)
+	}
+
+	if !options.Start.IsZero() {
+		query = query.Where("timestamp >= ?", options.Start)
+	}
+
+	if !options.End.IsZero() {
+		query = query.Where("timestamp <= ?", options.End)
+	}
+
+	result := query.
+		Order("id").
+		Limit(replayPageSize).
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entries, nil
+}
+
+// wait paces playback, either until Step is called or until the recorded gap between messages has elapsed
+func (r *Replayer) wait(ctx context.Context, options ReplayOptions, previous, next time.Time) error {
+	if options.Step {
+		select {
+		case <-r.step:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if options.Speed <= 0 || previous.IsZero() || !next.After(previous) {
+		return ctx.Err()
+	}
+
+	delay := time.Duration(float64(next.Sub(previous)) / options.Speed)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Replayer) send(ctx context.Context, entry Entry) error {
+	switch entry.Kind {
+	case TradeKind:
+		trade, err := entry.trade()
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case r.trades <- trade:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case QuoteKind:
+		quote, err := entry.quote()
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case r.quotes <- quote:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case BarKind:
+		bar, err := entry.bar()
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case r.bars <- bar:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package tape
+
+import (
+	"encoding/json"
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata/stream"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Kind identifies the type of message held by an Entry
+type Kind string
+
+const (
+	TradeKind Kind = "trade"
+	QuoteKind Kind = "quote"
+	BarKind   Kind = "bar"
+)
+
+// Entry is a single recorded stream message. Entries are replayed in ID order, i.e. the order they were received.
+type Entry struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	Kind       Kind      `gorm:"index"`
+	Symbol     string    `gorm:"index"`
+	Timestamp  time.Time `gorm:"index"`
+	ReceivedAt time.Time
+	Payload    []byte
+}
+
+// Open opens (creating if required) the tape database, which lives next to buffalo.db
+func Open() (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open("tape.db"), &gorm.Config{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(&Entry{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func newEntry(kind Kind, symbol string, timestamp time.Time, message any) (*Entry, error) {
+	payload, err := json.Marshal(message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		Kind:       kind,
+		Symbol:     symbol,
+		Timestamp:  timestamp,
+		ReceivedAt: time.Now(),
+		Payload:    payload,
+	}, nil
+}
+
+func (e *Entry) trade() (stream.Trade, error) {
+	var trade stream.Trade
+	err := json.Unmarshal(e.Payload, &trade)
+
+	return trade, err
+}
+
+func (e *Entry) quote() (stream.Quote, error) {
+	var quote stream.Quote
+	err := json.Unmarshal(e.Payload, &quote)
+
+	return quote, err
+}
+
+func (e *Entry) bar() (stream.Bar, error) {
+	var bar stream.Bar
+	err := json.Unmarshal(e.Payload, &bar)
+
+	return bar, err
+}
@@ -2,17 +2,18 @@ package asset
 
 import (
 	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/phoobynet/buffalo/data/market/source"
 	"gorm.io/gorm"
 	"log"
 	"strings"
 )
 
 type Repository struct {
-	db           *gorm.DB
-	alpacaClient *alpaca.Client
+	db             *gorm.DB
+	metadataSource source.MetadataSource
 }
 
-func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error) {
+func NewRepository(db *gorm.DB, metadataSource source.MetadataSource) (*Repository, error) {
 	err := db.AutoMigrate(&alpaca.Asset{})
 
 	if err != nil {
@@ -29,7 +30,7 @@ func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error
 
 	if count == 0 {
 		log.Println("Populating assets")
-		assets, err := alpacaClient.GetAssets(alpaca.GetAssetsRequest{
+		assets, err := metadataSource.GetAssets(alpaca.GetAssetsRequest{
 			Status: "active",
 		})
 
@@ -47,8 +48,8 @@ func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error
 	}
 
 	return &Repository{
-		db:           db,
-		alpacaClient: alpacaClient,
+		db:             db,
+		metadataSource: metadataSource,
 	}, nil
 }
 
@@ -79,3 +80,19 @@ func (r *Repository) GetAll() ([]alpaca.Asset, error) {
 
 	return assets, nil
 }
+
+// GetActive returns the active assets of the class
+func (r *Repository) GetActive(class alpaca.AssetClass) ([]alpaca.Asset, error) {
+	var assets []alpaca.Asset
+
+	result := r.db.
+		Where("class = ? AND status = ?", class, alpaca.AssetActive).
+		Order("symbol asc").
+		Find(&assets)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return assets, nil
+}
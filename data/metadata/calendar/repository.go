@@ -3,18 +3,19 @@ package calendar
 import (
 	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/golang-module/carbon/v2"
+	"github.com/phoobynet/buffalo/data/market/source"
 	"gorm.io/gorm"
 	"log"
 	"time"
 )
 
 type Repository struct {
-	alpacaClient *alpaca.Client
-	db           *gorm.DB
-	populated    bool
+	metadataSource source.MetadataSource
+	db             *gorm.DB
+	populated      bool
 }
 
-func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error) {
+func NewRepository(db *gorm.DB, metadataSource source.MetadataSource) (*Repository, error) {
 	err := db.AutoMigrate(&Calendar{})
 
 	if err != nil {
@@ -26,7 +27,7 @@ func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error
 	db.Model(&Calendar{}).Count(&count)
 
 	if count == 0 {
-		calendarDays, err := alpacaClient.GetCalendar(alpaca.GetCalendarRequest{
+		calendarDays, err := metadataSource.GetCalendar(alpaca.GetCalendarRequest{
 			Start: carbon.Now().SubYears(5).ToStdTime(),
 			End:   carbon.Now().AddYears(5).ToStdTime(),
 		})
@@ -60,8 +61,8 @@ func NewRepository(db *gorm.DB, alpacaClient *alpaca.Client) (*Repository, error
 	}
 
 	return &Repository{
-		alpacaClient: alpacaClient,
-		db:           db,
+		metadataSource: metadataSource,
+		db:             db,
 	}, nil
 }
 
@@ -98,3 +99,36 @@ func (r *Repository) PreviousCalendar() (*Calendar, error) {
 
 	return &calendar, nil
 }
+
+// Between returns the trading days from start to end inclusive, both formatted as 2006-01-02, oldest first
+func (r *Repository) Between(start, end string) ([]Calendar, error) {
+	var calendars []Calendar
+
+	result := r.db.
+		Model(&Calendar{}).
+		Where("date >= ? AND date <= ?", start, end).
+		Order("date asc").
+		Find(&calendars)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return calendars, nil
+}
+
+// Earliest returns the first trading day held by the calendar
+func (r *Repository) Earliest() (*Calendar, error) {
+	var calendar Calendar
+
+	result := r.db.
+		Model(&Calendar{}).
+		Order("date asc").
+		First(&calendar)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &calendar, nil
+}
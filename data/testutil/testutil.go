@@ -0,0 +1,71 @@
+// Package testutil provides the fixtures shared by the tests of the data packages
+package testutil
+
+import (
+	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
+	"github.com/phoobynet/buffalo/data/market/feed"
+	"github.com/phoobynet/buffalo/data/market/source/fake"
+	"github.com/phoobynet/buffalo/data/metadata/asset"
+	"github.com/phoobynet/buffalo/data/metadata/calendar"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+)
+
+// DB opens a database in the test's temporary directory
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// CloseDB closes the database, so that every later query fails
+func CloseDB(t testing.TB, db *gorm.DB) {
+	t.Helper()
+
+	sqlDB, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = sqlDB.Close()
+}
+
+// CalendarRepository returns a calendar repository populated from the fake market
+func CalendarRepository(t testing.TB, db *gorm.DB, market *fake.Market) *calendar.Repository {
+	t.Helper()
+
+	repository, err := calendar.NewRepository(db, market)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repository
+}
+
+// AssetRepository returns an asset repository populated from the fake market
+func AssetRepository(t testing.TB, db *gorm.DB, market *fake.Market) *asset.Repository {
+	t.Helper()
+
+	repository, err := asset.NewRepository(db, market)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repository
+}
+
+// FeedSelector returns a selector preferring SIP
+func FeedSelector() *feed.Selector {
+	return feed.NewSelector(marketdata.SIP, make(chan feed.Status, 10))
+}
@@ -0,0 +1,17 @@
+export interface Change {
+  horizon: string
+  date: string
+  price: number
+  available: boolean
+  change: number
+  // changePercent is in percent, e.g. 1.5 for 1.5%
+  changePercent: number
+}
+
+export interface Performance {
+  symbol: string
+  date: string
+  last: number
+  time: string
+  changes: Change[]
+}
@@ -0,0 +1,20 @@
+export interface Ticker {
+  symbol: string
+  sessionDate: string
+  last: number
+  lastTimestamp: string
+  bid: number
+  bidSize: number
+  ask: number
+  askSize: number
+  mid: number
+  spread: number
+  open: number
+  high: number
+  low: number
+  volume: number
+  vwap: number
+  previousClose: number
+  change: number
+  changePercent: number
+}
@@ -1,3 +1,5 @@
 export * from './StreamTrade'
 export * from './StreamQuote'
 export * from './StreamBar'
+export * from './Ticker'
+export * from './Performance'
@@ -1,19 +1,20 @@
 import { derived, writable } from 'svelte/store'
 import numeral from 'numeral'
-import { numberDiff } from '@/lib/numberDiff'
 import type { alpaca } from '../../../wailsjs/go/models'
 import type { marketdata } from '../../../wailsjs/go/models'
-import type { StreamQuote, StreamTrade } from '@/lib/types'
-import { formatISO } from 'date-fns'
+import type { Performance, StreamQuote, StreamTrade, Ticker } from '@/lib/types'
 
 export const symbol = writable<string>('')
 
 export const trade = writable<StreamTrade>()
 
-export const tradePriceFormatted = derived(trade, $trade => {
-  if (!$trade) return undefined
+// ticker holds the last price, change and session statistics calculated by the app
+export const ticker = writable<Ticker>()
 
-  return numeral($trade.p).format('$0,0.00')
+export const tradePriceFormatted = derived(ticker, $ticker => {
+  if (!$ticker?.last) return undefined
+
+  return numeral($ticker.last).format('$0,0.00')
 })
 
 export const quote = writable<StreamQuote>()
@@ -35,46 +36,54 @@ export const latestTrade = derived([trade, snapshot, symbol], ([$trade, $snapsho
 
 export const asset = writable<alpaca.Asset>()
 
-const prevDailyBar = derived(snapshot, $snapshot => {
-  if (!$snapshot) return undefined
-
-  const date = formatISO(Date.now(), {
-    representation: 'date',
-  })
-
-  if ($snapshot.dailyBar.t.substring(0, 10) !== date) {
-    return $snapshot.dailyBar
-  }
-
-  return $snapshot.prevDailyBar
-})
-
 export const assetNameShort = derived(asset, $asset => {
   if (!$asset) return undefined
 
   return $asset?.name?.replace('Common Stock', '').trim()
 })
 
-export const intradayDiff = derived([prevDailyBar, trade], ([$prevDailyBar, $trade]) => {
-  if (!$prevDailyBar || !$trade) return undefined
+export const changeSign = derived(ticker, $ticker => {
+  if (!$ticker?.previousClose || !$ticker?.last) return 0
 
-  return numberDiff($prevDailyBar.c, $trade.p)
+  return $ticker.change >= 0 ? 1 : -1
 })
 
-export const priceChangeAbs = derived(intradayDiff, $intradayDiff => {
-  if (!$intradayDiff) return undefined
+export const priceChangeAbs = derived(ticker, $ticker => {
+  if (!$ticker?.previousClose || !$ticker?.last) return undefined
 
-  return numeral(Math.abs($intradayDiff.change)).format('0.00')
+  return numeral(Math.abs($ticker.change)).format('0.00')
 })
 
-export const priceChangePercentAbs = derived(intradayDiff, $intradayDiff => {
-  if (!$intradayDiff) return undefined
+export const priceChangePercentAbs = derived(ticker, $ticker => {
+  if (!$ticker?.previousClose || !$ticker?.last) return undefined
 
-  return numeral(Math.abs($intradayDiff.changePercent)).format('0.00%')
+  return numeral(Math.abs($ticker.changePercent) / 100).format('0.00%')
 })
 
-export const previousCloseFormatted = derived(prevDailyBar, $prevDailyBar => {
-  if (!$prevDailyBar) return undefined
+export const previousCloseFormatted = derived(ticker, $ticker => {
+  if (!$ticker?.previousClose) return undefined
+
+  return numeral($ticker.previousClose).format('$0,0.00')
+})
 
-  return numeral($prevDailyBar.c).format('$0,0.00')
+// performance holds the change since each horizon, kept current by the "change-since" event
+export const performance = writable<Performance>()
+
+const horizonTitles: Record<string, string> = {
+  open: 'Open',
+  previous_close: 'Prev. Close',
+  pre_market_open: 'Pre-Market',
+  '52W_high': '52W High',
+  '52W_low': '52W Low',
+}
+
+export const changesSince = derived(performance, $performance => {
+  if (!$performance?.changes) return []
+
+  return $performance.changes
+    .filter(change => change.available)
+    .map(change => ({
+      title: horizonTitles[change.horizon] ?? change.horizon,
+      percentChange: change.changePercent,
+    }))
 })
@@ -8,6 +8,8 @@ export function GetAsset(arg1:string):Promise<any>;
 
 export function GetAssets():Promise<Array<any>>;
 
+export function GetChangeSince(arg1:string):Promise<any>;
+
 export function GetCurrentCalendar():Promise<any>;
 
 export function GetIntradayBars(arg1:string):Promise<Array<marketdata.Bar>>;
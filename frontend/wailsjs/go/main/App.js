@@ -14,6 +14,10 @@ export function GetAssets() {
   return window['go']['main']['App']['GetAssets']();
 }
 
+export function GetChangeSince(arg1) {
+  return window['go']['main']['App']['GetChangeSince'](arg1);
+}
+
 export function GetCurrentCalendar() {
   return window['go']['main']['App']['GetCurrentCalendar']();
 }